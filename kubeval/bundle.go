@@ -0,0 +1,405 @@
+package kubeval
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/go-multierror"
+	"github.com/xeipuuv/gojsonschema"
+)
+
+// BundleManifestName is the name of the file describing the contents of
+// a schema bundle, stored at the root of the bundle
+const BundleManifestName = "kubeval-bundle.json"
+
+// PullOptions describes which schemas to download into a schema bundle
+type PullOptions struct {
+	// SchemaLocation is the base URL from which to download schemas. It
+	// defaults to the same location used when validating.
+	SchemaLocation string
+
+	// OpenShift selects the OpenShift schemas rather than the upstream
+	// Kubernetes ones
+	OpenShift bool
+
+	// KubernetesVersions is the list of Kubernetes versions to download
+	// schemas for
+	KubernetesVersions []string
+
+	// Kinds restricts the bundle to the given case-insensitive kinds
+	Kinds []string
+
+	// Groups restricts the bundle to the given API groups, where "core"
+	// is the group of resources such as Pods and Services
+	Groups []string
+
+	// Strict includes the strict variant of every schema
+	Strict bool
+
+	// Output is the directory to write the bundle to, or a .tar.gz or
+	// .tgz file to write it as an archive
+	Output string
+}
+
+// BundleManifest describes the contents of a schema bundle
+type BundleManifest struct {
+	Source  string         `json:"source"`
+	Created time.Time      `json:"created"`
+	Schemas []BundleSchema `json:"schemas"`
+
+	// Missing lists the schemas which matched but weren't published at
+	// the source, so that they are known to be left out of the bundle
+	Missing []BundleSchema `json:"missing,omitempty"`
+}
+
+// BundleSchema describes a single schema within a schema bundle
+type BundleSchema struct {
+	Path              string `json:"path"`
+	KubernetesVersion string `json:"kubernetesVersion"`
+	APIVersion        string `json:"apiVersion"`
+	Kind              string `json:"kind"`
+	Strict            bool   `json:"strict"`
+	SHA256            string `json:"sha256,omitempty"`
+}
+
+// groupVersionKind identifies a type of Kubernetes resource
+type groupVersionKind struct {
+	Group   string `json:"group"`
+	Version string `json:"version"`
+	Kind    string `json:"kind"`
+}
+
+// APIVersion returns the apiVersion used in manifests of this type
+func (gvk groupVersionKind) APIVersion() string {
+	if gvk.Group == "" {
+		return gvk.Version
+	}
+	return gvk.Group + "/" + gvk.Version
+}
+
+// PullSchemas downloads the schemas selected by options into a bundle
+// which can be used offline with --schema-location. Schemas which aren't
+// published at the source are listed as missing in the manifest, rather
+// than failing the whole bundle.
+func PullSchemas(options PullOptions) (*BundleManifest, error) {
+	if options.Output == "" {
+		return nil, fmt.Errorf("An output directory or archive must be given")
+	}
+	baseURL := determineSchemaBaseURL(&Config{
+		SchemaLocation: options.SchemaLocation,
+		OpenShift:      options.OpenShift,
+	})
+	if isSchemaURLTemplate(baseURL) {
+		return nil, fmt.Errorf("Schemas can only be pulled from a base URL, not a template: %s", baseURL)
+	}
+	versions := options.KubernetesVersions
+	if len(versions) == 0 {
+		versions = []string{"master"}
+	}
+	strictness := []bool{false}
+	if options.Strict {
+		strictness = append(strictness, true)
+	}
+
+	manifest := &BundleManifest{
+		Source:  baseURL,
+		Created: time.Now().UTC(),
+	}
+	files := make(map[string][]byte)
+	var errors *multierror.Error
+
+	for _, version := range versions {
+		gvks, err := listSchemaKinds(baseURL, version)
+		if err != nil {
+			return nil, err
+		}
+		for _, gvk := range gvks {
+			if !matchesPullOptions(gvk, options) {
+				continue
+			}
+			for _, strict := range strictness {
+				config := &Config{KubernetesVersion: version, OpenShift: options.OpenShift, Strict: strict}
+				schemaURL := determineSchemaURL(baseURL, gvk.Kind, gvk.APIVersion(), config)
+				schema := BundleSchema{
+					Path:              strings.TrimPrefix(schemaURL, baseURL+"/"),
+					KubernetesVersion: version,
+					APIVersion:        gvk.APIVersion(),
+					Kind:              gvk.Kind,
+					Strict:            strict,
+				}
+				body, err := readLocation(context.Background(), schemaURL, nil)
+				if isNotFound(err) {
+					manifest.Missing = append(manifest.Missing, schema)
+					continue
+				} else if err != nil {
+					errors = multierror.Append(errors, fmt.Errorf("Failed downloading schema %s: %s", schemaURL, err))
+					continue
+				}
+				sum := sha256.Sum256(body)
+				schema.SHA256 = hex.EncodeToString(sum[:])
+				files[schema.Path] = body
+				manifest.Schemas = append(manifest.Schemas, schema)
+			}
+		}
+	}
+	if errors != nil {
+		errors.ErrorFormat = singleLineErrorFormat
+		return nil, errors
+	}
+	if len(manifest.Schemas) == 0 && len(manifest.Missing) > 0 {
+		return nil, fmt.Errorf("None of the %d schemas matching the given versions, kinds and groups were found", len(manifest.Missing))
+	}
+	if len(manifest.Schemas) == 0 {
+		return nil, fmt.Errorf("No schemas matched the given versions, kinds and groups")
+	}
+
+	manifestBody, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	files[BundleManifestName] = manifestBody
+
+	if isArchive(options.Output) {
+		err = writeBundleArchive(options.Output, files)
+	} else {
+		err = writeBundleDirectory(options.Output, files)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+// ListBundle returns the manifest of the bundle at the given directory or archive
+func ListBundle(bundle string) (*BundleManifest, error) {
+	files, err := readBundle(bundle)
+	if err != nil {
+		return nil, err
+	}
+	return bundleManifest(bundle, files)
+}
+
+// VerifyBundle checks that every schema listed in the manifest of the
+// bundle at the given directory or archive is present, unmodified and
+// a valid JSON schema
+func VerifyBundle(bundle string) (*BundleManifest, error) {
+	files, err := readBundle(bundle)
+	if err != nil {
+		return nil, err
+	}
+	manifest, err := bundleManifest(bundle, files)
+	if err != nil {
+		return nil, err
+	}
+
+	var errors *multierror.Error
+	for _, schema := range manifest.Schemas {
+		body, ok := files[schema.Path]
+		if !ok {
+			errors = multierror.Append(errors, fmt.Errorf("%s: Missing schema %s", bundle, schema.Path))
+			continue
+		}
+		sum := sha256.Sum256(body)
+		if hex.EncodeToString(sum[:]) != schema.SHA256 {
+			errors = multierror.Append(errors, fmt.Errorf("%s: Checksum mismatch for schema %s", bundle, schema.Path))
+			continue
+		}
+		if _, err := gojsonschema.NewSchema(gojsonschema.NewBytesLoader(body)); err != nil {
+			errors = multierror.Append(errors, fmt.Errorf("%s: Invalid schema %s: %s", bundle, schema.Path, err))
+		}
+	}
+	if errors != nil {
+		errors.ErrorFormat = singleLineErrorFormat
+	}
+	return manifest, errors.ErrorOrNil()
+}
+
+// listSchemaKinds returns the resource types for which schemas are
+// published for the given Kubernetes version, as listed in the
+// _definitions.json file alongside them
+func listSchemaKinds(baseURL, version string) ([]groupVersionKind, error) {
+	config := &Config{KubernetesVersion: version}
+	definitionsURL := fmt.Sprintf("%s/%s-standalone/_definitions.json", baseURL, normalisedKubernetesVersion(config))
+	body, err := readLocation(context.Background(), definitionsURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("Failed listing schemas from %s: %s", definitionsURL, err)
+	}
+
+	var definitions struct {
+		Definitions map[string]struct {
+			GroupVersionKinds []groupVersionKind `json:"x-kubernetes-group-version-kind"`
+		} `json:"definitions"`
+	}
+	if err := json.Unmarshal(body, &definitions); err != nil {
+		return nil, fmt.Errorf("Failed listing schemas from %s: %s", definitionsURL, err)
+	}
+
+	seen := make(map[groupVersionKind]bool)
+	var gvks []groupVersionKind
+	for _, definition := range definitions.Definitions {
+		// Types such as DeleteOptions are shared by every API group and
+		// don't have a schema of their own
+		if len(definition.GroupVersionKinds) != 1 {
+			continue
+		}
+		gvk := definition.GroupVersionKinds[0]
+		if !seen[gvk] {
+			seen[gvk] = true
+			gvks = append(gvks, gvk)
+		}
+	}
+	sort.Slice(gvks, func(i, j int) bool {
+		return gvks[i].APIVersion()+"/"+gvks[i].Kind < gvks[j].APIVersion()+"/"+gvks[j].Kind
+	})
+	return gvks, nil
+}
+
+func matchesPullOptions(gvk groupVersionKind, options PullOptions) bool {
+	if len(options.Kinds) > 0 {
+		matched := false
+		for _, kind := range options.Kinds {
+			if strings.EqualFold(kind, gvk.Kind) {
+				matched = true
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	if len(options.Groups) > 0 {
+		group := gvk.Group
+		if group == "" {
+			group = "core"
+		}
+		return in(options.Groups, group)
+	}
+	return true
+}
+
+func isArchive(bundle string) bool {
+	return strings.HasSuffix(bundle, ".tar.gz") || strings.HasSuffix(bundle, ".tgz")
+}
+
+func writeBundleDirectory(dir string, files map[string][]byte) error {
+	for name, body := range files {
+		target := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(target, body, 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeBundleArchive(archive string, files map[string][]byte) error {
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for _, name := range names {
+		header := &tar.Header{
+			Name:    name,
+			Mode:    0644,
+			Size:    int64(len(files[name])),
+			ModTime: time.Now(),
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if _, err := tw.Write(files[name]); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(archive, buf.Bytes(), 0644)
+}
+
+// readBundle returns the contents of every file in the bundle, keyed by
+// their slash-separated path within it
+func readBundle(bundle string) (map[string][]byte, error) {
+	files := make(map[string][]byte)
+	if !isArchive(bundle) {
+		err := filepath.Walk(bundle, func(p string, info os.FileInfo, err error) error {
+			if err != nil || info.IsDir() {
+				return err
+			}
+			body, err := ioutil.ReadFile(p)
+			if err != nil {
+				return err
+			}
+			rel, err := filepath.Rel(bundle, p)
+			if err != nil {
+				return err
+			}
+			files[filepath.ToSlash(rel)] = body
+			return nil
+		})
+		return files, err
+	}
+
+	f, err := os.Open(bundle)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", bundle, err)
+	}
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("%s: %s", bundle, err)
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		body, err := ioutil.ReadAll(tr)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", bundle, err)
+		}
+		files[path.Clean(header.Name)] = body
+	}
+	return files, nil
+}
+
+func bundleManifest(bundle string, files map[string][]byte) (*BundleManifest, error) {
+	body, ok := files[BundleManifestName]
+	if !ok {
+		return nil, fmt.Errorf("%s: Missing %s, not a schema bundle", bundle, BundleManifestName)
+	}
+	manifest := &BundleManifest{}
+	if err := json.Unmarshal(body, manifest); err != nil {
+		return nil, fmt.Errorf("%s: Invalid %s: %s", bundle, BundleManifestName, err)
+	}
+	return manifest, nil
+}
@@ -0,0 +1,117 @@
+package kubeval
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPullSchemas(t *testing.T) {
+	var requests int32
+	server := newSchemaServer(&requests)
+	defer server.Close()
+	tmpDir, _ := ioutil.TempDir("", "kubeval-bundle")
+	defer os.RemoveAll(tmpDir)
+
+	var tests = []struct {
+		Name    string
+		Output  string
+		Options PullOptions
+		Valid   bool
+		Missing []string
+	}{
+		{
+			Name:    "directory",
+			Output:  filepath.Join(tmpDir, "schemas"),
+			Options: PullOptions{Groups: []string{"core"}, Strict: true},
+			Valid:   true,
+		},
+		{
+			Name:    "archive",
+			Output:  filepath.Join(tmpDir, "schemas.tar.gz"),
+			Options: PullOptions{Kinds: []string{"replicationcontroller"}},
+			Valid:   true,
+		},
+		{
+			// the fixtures don't include a schema for Deployments
+			Name:    "missing_schema",
+			Output:  filepath.Join(tmpDir, "missing"),
+			Options: PullOptions{Groups: []string{"core", "apps"}},
+			Valid:   true,
+			Missing: []string{"master-standalone/deployment-apps-v1.json"},
+		},
+		{
+			Name:    "only_missing_schemas",
+			Output:  filepath.Join(tmpDir, "only_missing"),
+			Options: PullOptions{Groups: []string{"apps"}},
+			Valid:   false,
+		},
+	}
+	for _, test := range tests {
+		options := test.Options
+		options.SchemaLocation = server.URL
+		options.Output = test.Output
+		_, err := PullSchemas(options)
+		if (err == nil) != test.Valid {
+			t.Errorf("%s: expected valid=%t, got error: %v", test.Name, test.Valid, err)
+			continue
+		}
+		if !test.Valid {
+			continue
+		}
+
+		manifest, err := VerifyBundle(test.Output)
+		if err != nil {
+			t.Errorf("%s: unexpected error verifying bundle: %s", test.Name, err.Error())
+			continue
+		}
+		expectedSchemas := 1
+		if options.Strict {
+			expectedSchemas = 2
+		}
+		if len(manifest.Schemas) != expectedSchemas {
+			t.Errorf("%s: expected %d schemas in the bundle, got %+v", test.Name, expectedSchemas, manifest.Schemas)
+		}
+		for _, schema := range manifest.Schemas {
+			if schema.APIVersion != "v1" || schema.Kind != "ReplicationController" {
+				t.Errorf("%s: unexpected schema in the bundle %+v", test.Name, schema)
+			}
+		}
+		var missing []string
+		for _, schema := range manifest.Missing {
+			missing = append(missing, schema.Path)
+		}
+		assert.Equal(t, test.Missing, missing, test.Name)
+	}
+
+	// Both directory and archive bundles can be used as a schema location
+	fileContents, _ := ioutil.ReadFile("../fixtures/valid.yaml")
+	for _, bundle := range []string{"schemas", "schemas.tar.gz"} {
+		config := NewDefaultConfig()
+		config.FileName = "valid.yaml"
+		config.SchemaLocation = "file://" + filepath.Join(tmpDir, bundle)
+		results, err := Validate(fileContents, config)
+		if err != nil || !results[0].ValidatedAgainstSchema {
+			t.Errorf("Validate should use the schemas from the bundle %s, got error: %v", bundle, err)
+		}
+	}
+
+	// Schemas which aren't in an archive are missing
+	config := NewDefaultConfig()
+	config.SchemaLocation = "file://" + filepath.Join(tmpDir, "schemas.tar.gz")
+	_, err := Validate([]byte("apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: web\n"), config)
+	if err == nil || !strings.Contains(err.Error(), "is not in") {
+		t.Errorf("Expected a missing schema for a Deployment, got: %v", err)
+	}
+
+	// Modified schemas fail verification
+	bundle := filepath.Join(tmpDir, "schemas")
+	ioutil.WriteFile(filepath.Join(bundle, "master-standalone", "replicationcontroller-v1.json"), []byte("{}"), 0644)
+	if _, err := VerifyBundle(bundle); err == nil {
+		t.Errorf("VerifyBundle should fail when a schema has been modified")
+	}
+}
@@ -2,6 +2,7 @@ package kubeval
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/spf13/cobra"
 )
@@ -33,6 +34,27 @@ type Config struct {
 	// found at SchemaLocation
 	AdditionalSchemaLocations []string
 
+	// CRDLocations is a list of files and directories containing
+	// CustomResourceDefinitions, whose schemas are used to validate custom
+	// resources before searching any of the schema locations
+	CRDLocations []string
+
+	// OpenAPISpec is the URL or path of a Kubernetes OpenAPI v2 document
+	// (swagger.json), from which schemas are built for the resource types
+	// it defines. These are used before searching any of the schema locations.
+	OpenAPISpec string
+
+	// OpenAPIV3Location is the base URL or directory of a tree of Kubernetes
+	// OpenAPI v3 documents, laid out as an API server serves them under
+	// /openapi/v3. Schemas are built from the document for the group and
+	// version of each resource, before searching any of the schema locations.
+	OpenAPIV3Location string
+
+	// SchemaProvider finds the schema for each type of resource. When nil,
+	// the provider returned by NewDefaultSchemaProvider is used, which
+	// searches the CRD, OpenAPI and schema locations above.
+	SchemaProvider SchemaProvider
+
 	// OpenShift represents whether to test against
 	// upstream Kubernetes or the OpenShift schemas
 	OpenShift bool
@@ -41,6 +63,15 @@ type Config struct {
 	// the schema. The API allows them, but kubectl does not
 	Strict bool
 
+	// StrictFormats tells kubeval to check that values in the byte and
+	// quantity formats are base64 and Kubernetes resource quantities.
+	// Integer and int-or-string formats are always checked.
+	StrictFormats bool
+
+	// CheckYAML tells kubeval to report duplicate keys, and YAML constructs
+	// which decoders disagree about, as errors in each resource
+	CheckYAML bool
+
 	// IgnoreMissingSchemas tells kubeval whether to skip validation
 	// for resource definitions without an available schema
 	IgnoreMissingSchemas bool
@@ -56,6 +87,11 @@ type Config struct {
 	// KindsToReject is a list of case-sensitive prohibited kubernetes resources types
 	KindsToReject []string
 
+	// DuplicateTracker records the resources seen so far, to report any
+	// defined more than once. Share one between calls to find duplicates
+	// across inputs; when nil, duplicates are only found within an input.
+	DuplicateTracker *DuplicateTracker
+
 	// FileName is the name to be displayed when testing manifests read from stdin
 	FileName string
 
@@ -63,10 +99,33 @@ type Config struct {
 	// reporting results to the user.
 	OutputFormat string
 
+	// JSONVersion is the version of the format of JSON output: 1, the
+	// default, for a flat array of results, or 2 for a report describing
+	// each resource and error in full. Version 1 is used when it's 0.
+	JSONVersion int
+
 	// Quiet indicates whether non-results output should be emitted to the applications
 	// log.
 	Quiet bool
 
+	// CacheDir is the directory in which schemas downloaded over HTTP(S)
+	// are cached between runs. The on-disk cache is disabled when empty.
+	CacheDir string
+
+	// CacheTTL is how long schemas, and failures to find a schema, are
+	// kept in the on-disk cache
+	CacheTTL time.Duration
+
+	// NoCache tells kubeval to bypass the on-disk cache, neither reading
+	// from nor writing to it
+	NoCache bool
+
+	// Concurrency is the number of schemas to find at the same time before
+	// validating the resources in a manifest, and the number of files the
+	// command line reads ahead of the one it's validating. Values below 2
+	// mean one at a time.
+	Concurrency int
+
 	// InsecureSkipTLSVerify controls whether to skip TLS certificate validation
 	// when retrieving schema content over HTTPS
 	InsecureSkipTLSVerify bool
@@ -78,6 +137,7 @@ func NewDefaultConfig() *Config {
 		DefaultNamespace:  "default",
 		FileName:          "stdin",
 		KubernetesVersion: "master",
+		CacheTTL:          DefaultCacheTTL,
 	}
 }
 
@@ -88,14 +148,24 @@ func AddKubevalFlags(cmd *cobra.Command, config *Config) *cobra.Command {
 	cmd.Flags().BoolVar(&config.IgnoreMissingSchemas, "ignore-missing-schemas", false, "Skip validation for resource definitions without a schema")
 	cmd.Flags().BoolVar(&config.OpenShift, "openshift", false, "Use OpenShift schemas instead of upstream Kubernetes")
 	cmd.Flags().BoolVar(&config.Strict, "strict", false, "Disallow additional properties not in schema")
+	cmd.Flags().BoolVar(&config.StrictFormats, "strict-formats", false, "Check that byte values are base64 and quantities are valid Kubernetes resource quantities")
+	cmd.Flags().BoolVar(&config.CheckYAML, "check-yaml", false, "Report duplicate keys, YAML 1.1 booleans such as yes or on written as strings, merge keys and aliases")
 	cmd.Flags().StringVarP(&config.FileName, "filename", "f", "stdin", "filename to be displayed when testing manifests read from stdin")
 	cmd.Flags().StringSliceVar(&config.KindsToSkip, "skip-kinds", []string{}, "Comma-separated list of case-sensitive kinds to skip when validating against schemas")
 	cmd.Flags().StringSliceVar(&config.KindsToReject, "reject-kinds", []string{}, "Comma-separated list of case-sensitive kinds to prohibit validating against schemas")
-	cmd.Flags().StringVarP(&config.SchemaLocation, "schema-location", "s", "", "Base URL used to download schemas. Can also be specified with the environment variable KUBEVAL_SCHEMA_LOCATION.")
-	cmd.Flags().StringSliceVar(&config.AdditionalSchemaLocations, "additional-schema-locations", []string{}, "Comma-seperated list of secondary base URLs used to download schemas")
+	cmd.Flags().StringVarP(&config.SchemaLocation, "schema-location", "s", "", "Base URL used to download schemas, or a Go template for the URL of each schema. Can also be specified with the environment variable KUBEVAL_SCHEMA_LOCATION.")
+	cmd.Flags().StringSliceVar(&config.AdditionalSchemaLocations, "additional-schema-locations", []string{}, "Comma-seperated list of secondary base URLs, or Go templates, used to download schemas")
+	cmd.Flags().StringSliceVar(&config.CRDLocations, "crd-location", []string{}, "Comma-separated list of files or directories containing CustomResourceDefinitions to validate custom resources against")
+	cmd.Flags().StringVar(&config.OpenAPISpec, "openapi-spec", "", "URL or path of a Kubernetes OpenAPI v2 document (swagger.json) to build schemas from")
+	cmd.Flags().StringVar(&config.OpenAPIV3Location, "openapi-v3-location", "", "Base URL or directory of Kubernetes OpenAPI v3 documents, laid out as served under /openapi/v3, to build schemas from")
 	cmd.Flags().StringVarP(&config.KubernetesVersion, "kubernetes-version", "v", "master", "Version of Kubernetes to validate against")
 	cmd.Flags().StringVarP(&config.OutputFormat, "output", "o", "", fmt.Sprintf("The format of the output of this script. Options are: %v", validOutputs()))
+	cmd.Flags().IntVar(&config.JSONVersion, "json-version", jsonFormatV1, "The version of the format of JSON output. Version 2 describes each resource and error in full")
 	cmd.Flags().BoolVar(&config.Quiet, "quiet", false, "Silences any output aside from the direct results")
+	cmd.Flags().StringVar(&config.CacheDir, "cache-dir", DefaultCacheDir(), "Directory in which to cache downloaded schemas between runs")
+	cmd.Flags().DurationVar(&config.CacheTTL, "cache-ttl", DefaultCacheTTL, "How long to keep downloaded schemas in the cache")
+	cmd.Flags().BoolVar(&config.NoCache, "no-cache", false, "Bypass the on-disk schema cache")
+	cmd.Flags().IntVar(&config.Concurrency, "concurrency", 1, "Number of schemas to download at the same time")
 	cmd.Flags().BoolVar(&config.InsecureSkipTLSVerify, "insecure-skip-tls-verify", false, "If true, the server's certificate will not be checked for validity. This will make your HTTPS connections insecure")
 
 	return cmd
@@ -0,0 +1,281 @@
+package kubeval
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"sigs.k8s.io/yaml"
+)
+
+// isCustomResourceDefinition returns whether the given resource body
+// is a CustomResourceDefinition
+func isCustomResourceDefinition(body map[string]interface{}) bool {
+	kind, _ := getString(body, "kind")
+	apiVersion, _ := getString(body, "apiVersion")
+	return kind == "CustomResourceDefinition" && strings.HasPrefix(apiVersion, "apiextensions.k8s.io/")
+}
+
+// crdSchemas extracts the OpenAPI v3 schema of every version declared by
+// a CustomResourceDefinition, keyed in the same way as the schema cache.
+// Versions without a schema are left out.
+func crdSchemas(body map[string]interface{}) (map[string]map[string]interface{}, error) {
+	spec, err := getObject(body, "spec")
+	if err != nil {
+		return nil, err
+	}
+	group, err := getString(spec, "group")
+	if err != nil {
+		return nil, err
+	}
+	kind, err := getStringAt(spec, []string{"names", "kind"})
+	if err != nil {
+		return nil, err
+	}
+
+	// apiextensions.k8s.io/v1beta1 allows a single schema which applies
+	// to every version of the resource
+	var sharedSchema map[string]interface{}
+	if validation, err := getObject(spec, "validation"); err == nil {
+		sharedSchema, _ = getObject(validation, "openAPIV3Schema")
+	}
+
+	schemas := make(map[string]map[string]interface{})
+	if versions, ok := spec["versions"].([]interface{}); ok {
+		for _, v := range versions {
+			version, ok := v.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			name, err := getString(version, "name")
+			if err != nil {
+				return nil, fmt.Errorf("Invalid version in CustomResourceDefinition for %s: %s", kind, err.Error())
+			}
+			schema := sharedSchema
+			if versionSchema, err := getObject(version, "schema"); err == nil {
+				if openAPIV3Schema, err := getObject(versionSchema, "openAPIV3Schema"); err == nil {
+					schema = openAPIV3Schema
+				}
+			}
+			if schema != nil {
+				schemas[versionKind(group+"/"+name, kind)] = openAPIV3ToJSONSchema(schema)
+			}
+		}
+	} else if name, err := getString(spec, "version"); err == nil && sharedSchema != nil {
+		schemas[versionKind(group+"/"+name, kind)] = openAPIV3ToJSONSchema(sharedSchema)
+	}
+
+	return schemas, nil
+}
+
+// crdScope returns the API group and kind a CustomResourceDefinition
+// defines, and whether its resources are cluster-scoped rather than
+// namespaced, which is the default
+func crdScope(body map[string]interface{}) (string, string, bool, error) {
+	spec, err := getObject(body, "spec")
+	if err != nil {
+		return "", "", false, err
+	}
+	group, err := getString(spec, "group")
+	if err != nil {
+		return "", "", false, err
+	}
+	kind, err := getStringAt(spec, []string{"names", "kind"})
+	if err != nil {
+		return "", "", false, err
+	}
+	scope, _ := getString(spec, "scope")
+	return group, kind, scope == "Cluster", nil
+}
+
+// cacheCRDSchemas adds the schemas and scope declared by a
+// CustomResourceDefinition to the schema cache, so that custom resources
+// which follow it can be validated without a schema being published anywhere
+func cacheCRDSchemas(body map[string]interface{}, schemaCache *SchemaCache, config *Config) error {
+	schemas, err := crdSchemas(body)
+	if err != nil {
+		return err
+	}
+	group, kind, clusterScoped, err := crdScope(body)
+	if err != nil {
+		return err
+	}
+	schemaCache.putScope(group, kind, clusterScoped)
+	for key, raw := range schemas {
+		schema, err := compileSchema(key, raw, config)
+		if err != nil {
+			return err
+		}
+		schemaCache.put(key, schema)
+		schemaCache.putDocument(key, raw)
+		schemaCache.putLocation(key, config.FileName)
+	}
+	return nil
+}
+
+// crdDefinitions holds what the CustomResourceDefinitions read from disk
+// declare
+type crdDefinitions struct {
+	// schemas are keyed in the same way as the schema cache
+	schemas map[string]map[string]interface{}
+	// files holds the file each of the schemas was read from
+	files map[string]string
+	// scopes holds whether each kind is cluster-scoped, keyed by groupKind
+	scopes map[string]bool
+	// skipped holds the first error for each file with documents which
+	// couldn't be read as CustomResourceDefinitions
+	skipped []string
+}
+
+// loadCRDSchemas reads every CustomResourceDefinition found in the given
+// files and directories. Documents which can't be decoded, or which are
+// CustomResourceDefinitions without a valid schema, are skipped, so that
+// one of them doesn't keep the others from being used.
+func loadCRDSchemas(locations []string) (*crdDefinitions, error) {
+	definitions := &crdDefinitions{
+		schemas: make(map[string]map[string]interface{}),
+		files:   make(map[string]string),
+		scopes:  make(map[string]bool),
+	}
+	for _, location := range locations {
+		err := filepath.Walk(location, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if info.IsDir() {
+				return nil
+			}
+			if path != location && !hasManifestExtension(path) {
+				return nil
+			}
+			contents, err := ioutil.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			var skipped error
+			for _, document := range decodeDocuments(contents) {
+				var body map[string]interface{}
+				if err := yaml.Unmarshal(document.data, &body); err != nil {
+					if skipped == nil {
+						skipped = fmt.Errorf("Failed to decode YAML from %s: %s", path, err.Error())
+					}
+					continue
+				}
+				if body == nil || !isCustomResourceDefinition(body) {
+					continue
+				}
+				if err := definitions.add(path, body); err != nil && skipped == nil {
+					skipped = fmt.Errorf("%s: %s", path, err.Error())
+				}
+			}
+			if skipped != nil {
+				definitions.skipped = append(definitions.skipped, skipped.Error())
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return definitions, nil
+}
+
+// add records the schemas and scope declared by a CustomResourceDefinition
+// read from path
+func (d *crdDefinitions) add(path string, body map[string]interface{}) error {
+	schemas, err := crdSchemas(body)
+	if err != nil {
+		return err
+	}
+	group, kind, clusterScoped, err := crdScope(body)
+	if err != nil {
+		return err
+	}
+	for key, schema := range schemas {
+		d.schemas[key] = schema
+		d.files[key] = path
+	}
+	d.scopes[groupKind(group, kind)] = clusterScoped
+	return nil
+}
+
+// openAPIV3ToJSONSchema rewrites the OpenAPI v3 extensions used by
+// Kubernetes into their JSON schema equivalents. The schema is copied
+// rather than modified in place.
+func openAPIV3ToJSONSchema(schema map[string]interface{}) map[string]interface{} {
+	converted, _ := convertOpenAPIV3Node(schema).(map[string]interface{})
+	return converted
+}
+
+func convertOpenAPIV3Node(node interface{}) interface{} {
+	switch typed := node.(type) {
+	case map[string]interface{}:
+		converted := make(map[string]interface{}, len(typed))
+		for k, v := range typed {
+			converted[k] = convertOpenAPIV3Node(v)
+		}
+		// nullable: true has no JSON schema keyword, so allow null explicitly
+		if nullable, _ := converted["nullable"].(bool); nullable {
+			delete(converted, "nullable")
+			if t, ok := converted["type"].(string); ok {
+				converted["type"] = []interface{}{t, "null"}
+			}
+		}
+		return converted
+	case []interface{}:
+		converted := make([]interface{}, len(typed))
+		for i, v := range typed {
+			converted[i] = convertOpenAPIV3Node(v)
+		}
+		return converted
+	default:
+		return node
+	}
+}
+
+// strictJSONSchema returns a copy of the schema which prohibits properties
+// not declared in it, unless the schema explicitly allows them
+func strictJSONSchema(schema map[string]interface{}) map[string]interface{} {
+	strict := make(map[string]interface{}, len(schema)+1)
+	for k, v := range schema {
+		strict[k] = v
+	}
+
+	if properties, ok := schema["properties"].(map[string]interface{}); ok {
+		strictProperties := make(map[string]interface{}, len(properties))
+		for name, property := range properties {
+			strictProperties[name] = strictJSONSchemaNode(property)
+		}
+		strict["properties"] = strictProperties
+
+		_, hasAdditionalProperties := schema["additionalProperties"]
+		preserveUnknownFields, _ := schema["x-kubernetes-preserve-unknown-fields"].(bool)
+		if !hasAdditionalProperties && !preserveUnknownFields {
+			strict["additionalProperties"] = false
+		}
+	}
+	for _, key := range []string{"items", "additionalProperties", "not"} {
+		if _, ok := schema[key].(map[string]interface{}); ok {
+			strict[key] = strictJSONSchemaNode(schema[key])
+		}
+	}
+	for _, key := range []string{"allOf", "anyOf", "oneOf"} {
+		if subSchemas, ok := schema[key].([]interface{}); ok {
+			strictSubSchemas := make([]interface{}, len(subSchemas))
+			for i, subSchema := range subSchemas {
+				strictSubSchemas[i] = strictJSONSchemaNode(subSchema)
+			}
+			strict[key] = strictSubSchemas
+		}
+	}
+	return strict
+}
+
+func strictJSONSchemaNode(node interface{}) interface{} {
+	if schema, ok := node.(map[string]interface{}); ok {
+		return strictJSONSchema(schema)
+	}
+	return node
+}
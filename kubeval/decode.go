@@ -0,0 +1,398 @@
+package kubeval
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/xeipuuv/gojsonschema"
+	yamlv3 "gopkg.in/yaml.v3"
+)
+
+// jsonNumberPattern matches the numbers which are valid in JSON
+var jsonNumberPattern = regexp.MustCompile(`^-?(0|[1-9]\d*)(\.\d+)?([eE][+-]?\d+)?$`)
+
+// decodedResource is a resource decoded from YAML, keeping the numbers in
+// it exactly as written
+type decodedResource struct {
+	body map[string]interface{}
+	// decimals are the paths to the numbers written with a decimal point
+	// or exponent but with an integer value, such as 1.0, which JSON
+	// schema counts as integers while the API server doesn't
+	decimals [][]string
+}
+
+// decodeResource decodes the resource at node, or in data if node is nil,
+// into the values which would be sent to the API server. Numbers are
+// decoded as json.Number rather than float64, so that large integers keep
+// their precision. As with kubectl, YAML 1.1 booleans such as yes and on
+// are booleans, and merge keys and aliases are resolved.
+func decodeResource(data []byte, node *yamlv3.Node) (decodedResource, error) {
+	var decoded decodedResource
+	if node == nil {
+		var err error
+		if node, err = parseNode(data); err != nil || node == nil {
+			return decoded, err
+		}
+	}
+
+	value, err := decoded.value(node, nil)
+	if err != nil || value == nil {
+		return decoded, err
+	}
+	body, ok := value.(map[string]interface{})
+	if !ok {
+		return decoded, fmt.Errorf("line %d: cannot decode %s into a resource", node.Line, describeNodeKind(resolveAlias(node)))
+	}
+	decoded.body = body
+	return decoded, nil
+}
+
+// value returns the Go value of a node, which is found at path
+func (d *decodedResource) value(node *yamlv3.Node, path []string) (interface{}, error) {
+	node = resolveAlias(node)
+	switch node.Kind {
+	case yamlv3.MappingNode:
+		mapping := make(map[string]interface{}, len(node.Content)/2)
+		if err := d.mapping(node, path, mapping); err != nil {
+			return nil, err
+		}
+		return mapping, nil
+	case yamlv3.SequenceNode:
+		sequence := make([]interface{}, len(node.Content))
+		for i, item := range node.Content {
+			value, err := d.value(item, appendPath(path, strconv.Itoa(i)))
+			if err != nil {
+				return nil, err
+			}
+			sequence[i] = value
+		}
+		return sequence, nil
+	case yamlv3.ScalarNode:
+		return d.scalar(node, path)
+	}
+	return nil, nil
+}
+
+// mapping adds the keys and values of a mapping node to mapping. Keys
+// merged in with << don't replace keys written in the mapping itself, nor
+// those merged in before them.
+func (d *decodedResource) mapping(node *yamlv3.Node, path []string, mapping map[string]interface{}) error {
+	var merges []*yamlv3.Node
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		keyNode, valueNode := node.Content[i], node.Content[i+1]
+		if keyNode.Tag == "!!merge" {
+			merges = append(merges, valueNode)
+			continue
+		}
+		key, err := d.key(keyNode)
+		if err != nil {
+			return err
+		}
+		value, err := d.value(valueNode, appendPath(path, key))
+		if err != nil {
+			return err
+		}
+		mapping[key] = value
+	}
+
+	for _, merge := range merges {
+		sources := []*yamlv3.Node{resolveAlias(merge)}
+		if sources[0].Kind == yamlv3.SequenceNode {
+			sources = sources[0].Content
+		}
+		for _, source := range sources {
+			source = resolveAlias(source)
+			if source.Kind != yamlv3.MappingNode {
+				return fmt.Errorf("line %d: cannot merge %s into a mapping", source.Line, describeNodeKind(source))
+			}
+			merged := make(map[string]interface{})
+			if err := d.mapping(source, path, merged); err != nil {
+				return err
+			}
+			for key, value := range merged {
+				if _, ok := mapping[key]; !ok {
+					mapping[key] = value
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// key returns a mapping key as a string, in the same way as kubectl
+// converts YAML keys to JSON
+func (d *decodedResource) key(node *yamlv3.Node) (string, error) {
+	node = resolveAlias(node)
+	if node.Kind != yamlv3.ScalarNode {
+		return "", fmt.Errorf("line %d: cannot use %s as a key", node.Line, describeNodeKind(node))
+	}
+	value, err := (&decodedResource{}).scalar(node, nil)
+	if err != nil {
+		return "", err
+	}
+	switch typed := value.(type) {
+	case nil:
+		return "null", nil
+	case string:
+		return typed, nil
+	default:
+		return fmt.Sprint(typed), nil
+	}
+}
+
+// scalar returns the value of a scalar node, which is found at path
+func (d *decodedResource) scalar(node *yamlv3.Node, path []string) (interface{}, error) {
+	switch node.ShortTag() {
+	case "!!null":
+		return nil, nil
+	case "!!bool":
+		var value bool
+		err := node.Decode(&value)
+		return value, err
+	case "!!int":
+		var value interface{}
+		if err := node.Decode(&value); err != nil {
+			return nil, err
+		}
+		switch typed := value.(type) {
+		case int:
+			return json.Number(strconv.Itoa(typed)), nil
+		case int64:
+			return json.Number(strconv.FormatInt(typed, 10)), nil
+		case uint64:
+			return json.Number(strconv.FormatUint(typed, 10)), nil
+		}
+		// Integers too large for 64 bits are kept as written
+		if jsonNumberPattern.MatchString(node.Value) {
+			return json.Number(node.Value), nil
+		}
+		return nil, fmt.Errorf("line %d: %s can't be represented in JSON", node.Line, node.Value)
+	case "!!float":
+		var value float64
+		if err := node.Decode(&value); err != nil {
+			return nil, err
+		}
+		return d.float(node, value, path)
+	case "!!str":
+		// YAML 1.1, as used by kubectl, reads these plain strings as booleans
+		if node.Style == 0 && yamlBooleans[node.Value] {
+			return yamlBooleanValue(node.Value), nil
+		}
+	}
+	return node.Value, nil
+}
+
+// float returns a number written with a decimal point or exponent,
+// recording its path if it has an integer value
+func (d *decodedResource) float(node *yamlv3.Node, value float64, path []string) (interface{}, error) {
+	if math.IsInf(value, 0) || math.IsNaN(value) {
+		return nil, fmt.Errorf("line %d: %s can't be represented in JSON", node.Line, node.Value)
+	}
+	number := json.Number(strconv.FormatFloat(value, 'g', -1, 64))
+	if jsonNumberPattern.MatchString(node.Value) {
+		number = json.Number(node.Value)
+	}
+	// Integers too wide for 64 bits are resolved as floats too, but aren't
+	// decimals as they're written without a decimal point or exponent
+	if !strings.ContainsAny(node.Value, ".eE") {
+		return number, nil
+	}
+	if exact, ok := new(big.Float).SetString(string(number)); ok && exact.IsInt() {
+		d.decimals = append(d.decimals, path)
+	}
+	return number, nil
+}
+
+// stringAt returns the string found by following the given fields from
+// node, without decoding the rest of the tree
+func stringAt(node *yamlv3.Node, fields ...string) (string, bool) {
+	for _, field := range fields {
+		_, node = lookupNode(node, field)
+	}
+	node = resolveAlias(node)
+	if node == nil || node.Kind != yamlv3.ScalarNode {
+		return "", false
+	}
+	value, err := (&decodedResource{}).scalar(node, nil)
+	str, ok := value.(string)
+	return str, err == nil && ok
+}
+
+// yamlBooleanValue returns the value of one of yamlBooleans
+func yamlBooleanValue(value string) bool {
+	switch value {
+	case "y", "Y", "yes", "Yes", "YES", "on", "On", "ON":
+		return true
+	}
+	return false
+}
+
+// describeNodeKind names the kind of a node for use in errors
+func describeNodeKind(node *yamlv3.Node) string {
+	switch node.Kind {
+	case yamlv3.MappingNode:
+		return "a mapping"
+	case yamlv3.SequenceNode:
+		return "a sequence"
+	}
+	return "a scalar"
+}
+
+// appendPath returns a copy of path with field added to the end
+func appendPath(path []string, field string) []string {
+	extended := make([]string, len(path), len(path)+1)
+	copy(extended, path)
+	return append(extended, field)
+}
+
+// integerErrorTypes are the errors which JSON schema reports for a number
+// which isn't an integer where one is required
+var integerErrorTypes = []string{"invalid_type", "number_one_of", "number_any_of", "number_all_of"}
+
+// integerFormats are the formats which require an integer
+var integerFormats = []string{"int32", "int64", "int-or-string"}
+
+// checkDecimalIntegers returns an error for each number written with a
+// decimal point, such as 1.0, where the schema requires an integer. JSON
+// schema counts these as integers, but the API server rejects them, so
+// each is found by validating again with the numbers replaced by 0.5 and
+// seeing which of them are then rejected. Numbers which already have an
+// error are left alone.
+func checkDecimalIntegers(schema *gojsonschema.Schema, decoded decodedResource, existing []gojsonschema.ResultError) []gojsonschema.ResultError {
+	var probed [][]string
+	var body interface{} = decoded.body
+	for _, path := range decoded.decimals {
+		if hasErrorAt(existing, path) {
+			continue
+		}
+		body = replaceValue(body, path, json.Number("0.5"))
+		probed = append(probed, path)
+	}
+	if len(probed) == 0 {
+		return nil
+	}
+
+	results, err := schema.Validate(gojsonschema.NewGoLoader(body))
+	if err != nil {
+		return nil
+	}
+	var errors []gojsonschema.ResultError
+	for _, path := range probed {
+		for _, probeErr := range results.Errors() {
+			if !equalPaths(errorPath(probeErr), path) || !requiresInteger(probeErr) {
+				continue
+			}
+			value, _ := lookupValue(decoded.body, path)
+			err := &gojsonschema.ResultErrorFields{}
+			err.SetType("invalid_type")
+			err.SetContext(pathContext(path))
+			err.SetValue(value)
+			err.SetDetails(gojsonschema.ErrorDetails{"expected": gojsonschema.TYPE_INTEGER, "given": gojsonschema.TYPE_NUMBER})
+			err.SetDescription(fmt.Sprintf("Invalid type. Expected: %s, given: %s (%v)", gojsonschema.TYPE_INTEGER, gojsonschema.TYPE_NUMBER, value))
+			errors = append(errors, err)
+			break
+		}
+	}
+	return errors
+}
+
+// requiresInteger returns whether an error for a number which isn't an
+// integer means that the schema requires one
+func requiresInteger(err gojsonschema.ResultError) bool {
+	if err.Type() == "format" {
+		format, _ := err.Details()["format"].(string)
+		return in(integerFormats, format)
+	}
+	return in(integerErrorTypes, err.Type())
+}
+
+// hasErrorAt returns whether any of errors is about the value at path
+func hasErrorAt(errors []gojsonschema.ResultError, path []string) bool {
+	for _, err := range errors {
+		if equalPaths(errorPath(err), path) {
+			return true
+		}
+	}
+	return false
+}
+
+func equalPaths(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// pathContext returns the context gojsonschema would give an error about
+// the value at path
+func pathContext(path []string) *gojsonschema.JsonContext {
+	context := gojsonschema.NewJsonContext(gojsonschema.STRING_CONTEXT_ROOT, nil)
+	for _, field := range path {
+		context = gojsonschema.NewJsonContext(field, context)
+	}
+	return context
+}
+
+// lookupValue returns the value at path within value
+func lookupValue(value interface{}, path []string) (interface{}, bool) {
+	for _, field := range path {
+		switch typed := value.(type) {
+		case map[string]interface{}:
+			next, ok := typed[field]
+			if !ok {
+				return nil, false
+			}
+			value = next
+		case []interface{}:
+			i, err := strconv.Atoi(field)
+			if err != nil || i < 0 || i >= len(typed) {
+				return nil, false
+			}
+			value = typed[i]
+		default:
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+// replaceValue returns a copy of value with the value at path replaced,
+// copying only the mappings and sequences along path
+func replaceValue(value interface{}, path []string, replacement interface{}) interface{} {
+	if len(path) == 0 {
+		return replacement
+	}
+	switch typed := value.(type) {
+	case map[string]interface{}:
+		next, ok := typed[path[0]]
+		if !ok {
+			return value
+		}
+		copied := make(map[string]interface{}, len(typed))
+		for key, item := range typed {
+			copied[key] = item
+		}
+		copied[path[0]] = replaceValue(next, path[1:], replacement)
+		return copied
+	case []interface{}:
+		i, err := strconv.Atoi(path[0])
+		if err != nil || i < 0 || i >= len(typed) {
+			return value
+		}
+		copied := make([]interface{}, len(typed))
+		copy(copied, typed)
+		copied[i] = replaceValue(typed[i], path[1:], replacement)
+		return copied
+	}
+	return value
+}
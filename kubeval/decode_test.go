@@ -0,0 +1,79 @@
+package kubeval
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecodeResource(t *testing.T) {
+	input := []byte(`base: &base
+  enabled: on
+  count: 1
+values:
+  <<: *base
+  count: 2
+  large: 9007199254740993
+  wide: 12345678901234567890123
+  decimal: 1.0
+  exponent: 1e3
+  fraction: 1.5
+  quoted: "yes"
+  list: [*base]
+`)
+	decoded, err := decodeResource(input, nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	values := decoded.body["values"].(map[string]interface{})
+	assert.Equal(t, true, values["enabled"])
+	assert.Equal(t, json.Number("2"), values["count"])
+	assert.Equal(t, json.Number("9007199254740993"), values["large"])
+	assert.Equal(t, json.Number("12345678901234567890123"), values["wide"])
+	assert.Equal(t, json.Number("1.0"), values["decimal"])
+	assert.Equal(t, json.Number("1.5"), values["fraction"])
+	assert.Equal(t, "yes", values["quoted"])
+	assert.Equal(t, []interface{}{map[string]interface{}{"enabled": true, "count": json.Number("1")}}, values["list"])
+	assert.Equal(t, [][]string{{"values", "decimal"}, {"values", "exponent"}}, decoded.decimals)
+}
+
+func TestDecodeResourceErrors(t *testing.T) {
+	for _, input := range []string{"- a\n- b\n", "value: .inf\n", "value: [\n"} {
+		if _, err := decodeResource([]byte(input), nil); err == nil {
+			t.Errorf("Expected an error decoding %q", input)
+		}
+	}
+}
+
+func TestValidateDecimalIntegers(t *testing.T) {
+	var tests = []struct {
+		Name     string
+		Replicas string
+		Errors   []string
+	}{
+		{"integer", "3", nil},
+		{"decimal", "3.0", []string{"invalid_type"}},
+		{"exponent", "3e0", []string{"invalid_type"}},
+		{"fraction", "3.5", []string{"invalid_type"}},
+		{"precise int64", "9223372036854775807", []string{"format"}},
+		{"wider than 64 bits", "12345678901234567890123", []string{"format"}},
+	}
+	schemaLocation, _ := filepath.Abs("../fixtures/schemas")
+	for _, test := range tests {
+		input := []byte("apiVersion: v1\nkind: ReplicationController\nmetadata:\n  name: decimal\nspec:\n  replicas: " + test.Replicas + "\n")
+		config := NewDefaultConfig()
+		config.SchemaLocation = "file://" + schemaLocation
+		results, err := Validate(input, config)
+		if err != nil {
+			t.Fatalf("%s: Unexpected error: %s", test.Name, err.Error())
+		}
+		var types []string
+		for _, err := range results[0].Errors {
+			assert.Equal(t, "spec.replicas", err.Field(), test.Name)
+			types = append(types, err.Type())
+		}
+		assert.Equal(t, test.Errors, types, test.Name)
+	}
+}
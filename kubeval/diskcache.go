@@ -0,0 +1,194 @@
+package kubeval
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultCacheTTL is how long schemas are kept in the on-disk cache
+// before they are downloaded again
+const DefaultCacheTTL = 24 * time.Hour
+
+// DefaultCacheDir returns the directory in which kubeval caches schemas
+// on disk, which follows the XDG base directory specification on Linux.
+// It returns an empty string if no cache directory is available.
+func DefaultCacheDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "kubeval", "schemas")
+}
+
+// diskCache stores the responses for remote schema URLs on disk,
+// including the failure to find a schema, so that separate runs of
+// kubeval don't need to download the same schemas again
+type diskCache struct {
+	dir string
+	ttl time.Duration
+}
+
+// newDiskCache returns the on-disk cache for the given configuration,
+// or nil if caching is disabled
+func newDiskCache(config *Config) *diskCache {
+	if config.CacheDir == "" || config.NoCache {
+		return nil
+	}
+	return &diskCache{
+		dir: config.CacheDir,
+		ttl: config.CacheTTL,
+	}
+}
+
+// path returns the location of the cache entry for the given URL. Failures
+// are stored separately from schemas, with the error message as the content.
+func (d *diskCache) path(url string, failure bool) string {
+	sum := sha256.Sum256([]byte(url))
+	ext := ".json"
+	if failure {
+		ext = ".err"
+	}
+	return filepath.Join(d.dir, hex.EncodeToString(sum[:])+ext)
+}
+
+// read returns the contents of the cache entry at path if it has not expired
+func (d *diskCache) read(path string) ([]byte, bool) {
+	info, err := os.Stat(path)
+	if err != nil || time.Since(info.ModTime()) > d.ttl {
+		return nil, false
+	}
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, false
+	}
+	return contents, true
+}
+
+// write atomically replaces the cache entry at path
+func (d *diskCache) write(path string, contents []byte) error {
+	if err := os.MkdirAll(d.dir, 0755); err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(d.dir, ".tmp-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(contents); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// fetch returns the body found at url, from the cache if a fresh entry
+// exists. Responses saying there is nothing at the URL are cached as
+// failures; other errors, such as network errors or a server failing to
+// respond, are not cached, as they are likely transient.
+func (d *diskCache) fetch(ctx context.Context, url string) ([]byte, error) {
+	if body, ok := d.read(d.path(url, false)); ok {
+		return body, nil
+	}
+	if status, ok := d.read(d.path(url, true)); ok {
+		if statusErr := newHTTPStatusError(string(status)); statusErr.notFound() {
+			return nil, statusErr
+		}
+	}
+
+	body, err := httpGet(ctx, url)
+	var statusErr *httpStatusError
+	if errors.As(err, &statusErr) && statusErr.notFound() {
+		// Failing to write to the cache shouldn't fail validation, so
+		// errors are deliberately ignored here and below
+		_ = d.write(d.path(url, true), []byte(statusErr.status))
+		return nil, err
+	}
+	if err != nil {
+		return nil, err
+	}
+	_ = d.write(d.path(url, false), body)
+	os.Remove(d.path(url, true))
+	return body, nil
+}
+
+// httpStatusError is returned when a schema could not be downloaded
+// because the server responded with an unsuccessful status
+type httpStatusError struct {
+	statusCode int
+	status     string
+}
+
+// newHTTPStatusError returns the error for a response status such as
+// "404 Not Found"
+func newHTTPStatusError(status string) *httpStatusError {
+	code, _ := strconv.Atoi(strings.SplitN(status, " ", 2)[0])
+	return &httpStatusError{statusCode: code, status: status}
+}
+
+// notFound returns whether the status says there is nothing at the URL,
+// rather than that the server couldn't answer
+func (e *httpStatusError) notFound() bool {
+	return e.statusCode == http.StatusNotFound || e.statusCode == http.StatusGone
+}
+
+func (e *httpStatusError) Error() string {
+	return fmt.Sprintf("Could not read schema from HTTP, response status is %s", e.status)
+}
+
+// isNotFound returns whether reading a location failed because there is
+// nothing there, rather than because it couldn't be read
+func isNotFound(err error) bool {
+	var statusErr *httpStatusError
+	return errors.Is(err, os.ErrNotExist) || (errors.As(err, &statusErr) && statusErr.notFound())
+}
+
+// httpClient downloads schemas and OpenAPI documents, giving up on a
+// server which takes too long to respond
+var httpClient = &http.Client{Timeout: time.Minute}
+
+// httpGet downloads the body found at url, stopping if ctx is done
+func httpGet(ctx context.Context, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, &httpStatusError{statusCode: resp.StatusCode, status: resp.Status}
+	}
+	return ioutil.ReadAll(resp.Body)
+}
+
+// readLocation reads the document at a remote URL or local path, going
+// through the on-disk cache for remote documents unless cache is nil
+func readLocation(ctx context.Context, location string, cache *diskCache) ([]byte, error) {
+	if !isRemote(location) {
+		return ioutil.ReadFile(strings.TrimPrefix(location, "file://"))
+	}
+	if cache != nil {
+		return cache.fetch(ctx, location)
+	}
+	return httpGet(ctx, location)
+}
+
+// isRemote returns whether the reference points at an HTTP(S) location
+func isRemote(ref string) bool {
+	return strings.HasPrefix(ref, "http://") || strings.HasPrefix(ref, "https://")
+}
@@ -0,0 +1,204 @@
+package kubeval
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newSchemaServer serves the schemas in fixtures/schemas, counting the
+// number of requests it receives
+func newSchemaServer(requests *int32) *httptest.Server {
+	files := http.FileServer(http.Dir("../fixtures/schemas"))
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(requests, 1)
+		files.ServeHTTP(w, r)
+	}))
+}
+
+func TestDiskCache(t *testing.T) {
+	var tests = []struct {
+		Name             string
+		Fixture          string
+		NoCache          bool
+		Expire           bool
+		ExpectedRequests int32
+	}{
+		{
+			Name:             "cached_schema",
+			Fixture:          "valid.yaml",
+			ExpectedRequests: 1,
+		},
+		{
+			Name:             "cached_missing_schema",
+			Fixture:          "test_crd.yaml",
+			ExpectedRequests: 1,
+		},
+		{
+			Name:             "expired_schema",
+			Fixture:          "valid.yaml",
+			Expire:           true,
+			ExpectedRequests: 2,
+		},
+		{
+			Name:             "bypassed_cache",
+			Fixture:          "valid.yaml",
+			NoCache:          true,
+			ExpectedRequests: 2,
+		},
+	}
+	for _, test := range tests {
+		var requests int32
+		server := newSchemaServer(&requests)
+		cacheDir, _ := ioutil.TempDir("", "kubeval-cache")
+
+		filePath, _ := filepath.Abs("../fixtures/" + test.Fixture)
+		fileContents, _ := ioutil.ReadFile(filePath)
+		config := NewDefaultConfig()
+		config.FileName = test.Fixture
+		config.SchemaLocation = server.URL
+		config.CacheDir = cacheDir
+		config.NoCache = test.NoCache
+		config.IgnoreMissingSchemas = true
+
+		// Each call to Validate uses a new in-memory cache
+		Validate(fileContents, config)
+		if test.Expire {
+			expired := time.Now().Add(-2 * DefaultCacheTTL)
+			files, _ := filepath.Glob(filepath.Join(cacheDir, "*"))
+			for _, f := range files {
+				os.Chtimes(f, expired, expired)
+			}
+		}
+		results, err := Validate(fileContents, config)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", test.Name, err.Error())
+		}
+		if test.Fixture == "valid.yaml" && !results[0].ValidatedAgainstSchema {
+			t.Errorf("%s: resource should be validated against a schema", test.Name)
+		}
+		if requests != test.ExpectedRequests {
+			t.Errorf("%s: expected %d schema downloads, got %d", test.Name, test.ExpectedRequests, requests)
+		}
+
+		server.Close()
+		os.RemoveAll(cacheDir)
+	}
+}
+
+func TestDiskCacheServerErrors(t *testing.T) {
+	for _, status := range []int{http.StatusInternalServerError, http.StatusServiceUnavailable, http.StatusTooManyRequests} {
+		var requests int32
+		files := http.FileServer(http.Dir("../fixtures/schemas"))
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if atomic.AddInt32(&requests, 1) == 1 {
+				w.WriteHeader(status)
+				return
+			}
+			files.ServeHTTP(w, r)
+		}))
+		cacheDir, _ := ioutil.TempDir("", "kubeval-cache")
+
+		fileContents, _ := ioutil.ReadFile("../fixtures/valid.yaml")
+		config := NewDefaultConfig()
+		config.FileName = "valid.yaml"
+		config.SchemaLocation = server.URL
+		config.CacheDir = cacheDir
+
+		// The failure isn't cached, so the schema is downloaded next time
+		if _, err := Validate(fileContents, config); err == nil {
+			t.Errorf("%d: expected an error while the server is failing", status)
+		}
+		results, err := Validate(fileContents, config)
+		if err != nil {
+			t.Errorf("%d: unexpected error: %s", status, err.Error())
+		} else if !results[0].ValidatedAgainstSchema {
+			t.Errorf("%d: resource should be validated against a schema", status)
+		}
+		if requests != 2 {
+			t.Errorf("%d: expected 2 schema downloads, got %d", status, requests)
+		}
+
+		server.Close()
+		os.RemoveAll(cacheDir)
+	}
+}
+
+func TestRelativeSchemaReferences(t *testing.T) {
+	for _, noCache := range []bool{false, true} {
+		var requests int32
+		files := http.FileServer(http.Dir("../fixtures/referenced-schemas"))
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&requests, 1)
+			files.ServeHTTP(w, r)
+		}))
+		cacheDir, _ := ioutil.TempDir("", "kubeval-cache")
+
+		config := NewDefaultConfig()
+		config.FileName = "configmap.yaml"
+		config.SchemaLocation = server.URL
+		config.CacheDir = cacheDir
+		config.NoCache = noCache
+
+		// The name is checked against the schema the metadata refers to
+		input := []byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: 1\n")
+		for i := 0; i < 2; i++ {
+			results, err := Validate(input, config)
+			if err != nil {
+				t.Errorf("no cache %t: unexpected error: %s", noCache, err.Error())
+			} else if len(results[0].Errors) != 1 || results[0].Errors[0].Field() != "metadata.name" {
+				t.Errorf("no cache %t: expected an error for metadata.name, got %v", noCache, results[0].Errors)
+			}
+		}
+		// The schema and the definitions it refers to are read from the
+		// cache the second time, if there is one
+		expectedRequests := int32(2)
+		if noCache {
+			expectedRequests = 4
+		}
+		if requests != expectedRequests {
+			t.Errorf("no cache %t: expected %d schema downloads, got %d", noCache, expectedRequests, requests)
+		}
+
+		server.Close()
+		os.RemoveAll(cacheDir)
+	}
+}
+
+func TestDownloadsStopWhenCancelled(t *testing.T) {
+	// The server doesn't respond until the test is over
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	config := NewDefaultConfig()
+	config.FileName = "valid.yaml"
+	config.SchemaLocation = server.URL
+	config.NoCache = true
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	fileContents, _ := ioutil.ReadFile("../fixtures/valid.yaml")
+	done := make(chan error, 1)
+	go func() {
+		done <- ValidateStream(ctx, strings.NewReader(string(fileContents)), NewSharedSchemaCache(), func(ValidationResult) error { return nil }, config)
+	}()
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Errorf("Expected an error when the download is cancelled")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Expected the download to stop when the context is done")
+	}
+}
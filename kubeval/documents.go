@@ -0,0 +1,212 @@
+package kubeval
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+
+	yamlv3 "gopkg.in/yaml.v3"
+)
+
+// byteOrderMark is the UTF-8 encoding of the byte order mark
+var byteOrderMark = []byte("\xef\xbb\xbf")
+
+// document is a single YAML document from a stream of them
+type document struct {
+	// data holds the document as it appears in the stream, including
+	// any --- marker which starts it and comments which precede it
+	data []byte
+	// offset is the byte offset of data within the stream
+	offset int
+	// line is the 1-based line number of the first line of data
+	line int
+	// index is the 0-based index of the document within the stream
+	index int
+	// node is the root of the document's node tree once it's parsed, or
+	// the node of the resource within its List's tree for an item of a
+	// List, whose data is then empty
+	node *yamlv3.Node
+	// parsed is whether node has been set, and parseErr why data couldn't
+	// be parsed if it couldn't
+	parsed   bool
+	parseErr error
+	// listPath leads to data from the List it is an item of, if any
+	listPath ListPath
+	// envelope is whether data is a List, to be validated without its items
+	envelope bool
+}
+
+// parse parses data into the document's node tree, unless that's
+// already been done, so that each document is only parsed once however
+// many times it's looked at
+func (d *document) parse() {
+	if d.parsed {
+		return
+	}
+	d.parsed = true
+	d.node, d.parseErr = parseNode(d.data)
+}
+
+// decode decodes the resource in the document from its node tree
+func (d *document) decode() (decodedResource, error) {
+	d.parse()
+	if d.parseErr != nil {
+		return decodedResource{}, d.parseErr
+	}
+	if d.node == nil {
+		return decodedResource{}, nil
+	}
+	return decodeResource(nil, d.node)
+}
+
+// documentDecoder reads the documents from a multi-document YAML stream
+// without parsing them. Documents are separated by a --- marker, or ended
+// by a ... marker, at the start of a line and followed by whitespace, a
+// comment or the end of the line, as in the YAML specification. Unlike
+// yaml.v3's Decoder, a syntax error in one document doesn't stop the rest
+// being read, and the text of each document is kept.
+type documentDecoder struct {
+	reader *bufio.Reader
+
+	// offset and line are the position of the next line to be read
+	offset int
+	line   int
+
+	// current is the document being read
+	current document
+	// started is whether current began with a --- marker
+	started bool
+	// content is whether current has anything other than markers,
+	// comments, directives and blank lines
+	content bool
+	// yielded is the number of documents returned so far
+	yielded int
+	eof     bool
+}
+
+// newDocumentDecoder returns a decoder for the YAML stream read from r
+func newDocumentDecoder(r io.Reader) *documentDecoder {
+	d := &documentDecoder{
+		reader: bufio.NewReader(r),
+		line:   1,
+	}
+	d.reset()
+	return d
+}
+
+// decodeDocuments returns every document in a multi-document YAML input
+func decodeDocuments(input []byte) []document {
+	decoder := newDocumentDecoder(bytes.NewReader(input))
+	var documents []document
+	for {
+		doc, err := decoder.next()
+		if err != nil {
+			// Reading from memory only fails at the end of the input
+			return documents
+		}
+		documents = append(documents, doc)
+	}
+}
+
+// next returns the next document in the stream, or io.EOF once there are
+// none left. An empty document between two markers is returned with the
+// markers and comments it has, so that it can be reported. Markers at the
+// very start or end of the stream don't create documents of their own.
+func (d *documentDecoder) next() (document, error) {
+	for !d.eof {
+		raw, err := d.reader.ReadBytes('\n')
+		if err == io.EOF {
+			d.eof = true
+		} else if err != nil {
+			return document{}, err
+		}
+		if len(raw) == 0 {
+			break
+		}
+		offset, line := d.offset, d.line
+		d.offset += len(raw)
+		d.line++
+
+		// A byte order mark may precede the first marker
+		trimmed := raw
+		if offset == 0 {
+			trimmed = bytes.TrimPrefix(raw, byteOrderMark)
+		}
+
+		switch {
+		case isDocumentMarker(trimmed, "---"):
+			if d.started || d.content {
+				doc := d.finish()
+				d.reset()
+				d.current.offset, d.current.line = offset, line
+				d.current.data = append(d.current.data, raw...)
+				d.started = true
+				d.content = hasInlineContent(trimmed)
+				return doc, nil
+			}
+			// Anything before the marker is a comment or a directive,
+			// which belongs to the document the marker starts
+			d.current.data = append(d.current.data, raw...)
+			d.started = true
+			d.content = hasInlineContent(trimmed)
+		case isDocumentMarker(trimmed, "..."):
+			if d.started || d.content {
+				doc := d.finish()
+				d.reset()
+				return doc, nil
+			}
+			d.reset()
+		default:
+			d.current.data = append(d.current.data, raw...)
+			if !isBlankOrComment(trimmed) && trimmed[0] != '%' {
+				d.content = true
+			}
+		}
+	}
+
+	// The last document is only returned if it has content, unless it's
+	// the only one, so that a trailing --- doesn't add an empty document
+	if d.content || (d.yielded == 0 && len(d.current.data) > 0) {
+		doc := d.finish()
+		d.reset()
+		return doc, nil
+	}
+	return document{}, io.EOF
+}
+
+// finish returns the current document and counts it as yielded
+func (d *documentDecoder) finish() document {
+	doc := d.current
+	doc.index = d.yielded
+	d.yielded++
+	return doc
+}
+
+// reset starts a new document at the position of the next line
+func (d *documentDecoder) reset() {
+	d.current = document{offset: d.offset, line: d.line}
+	d.started = false
+	d.content = false
+}
+
+// isDocumentMarker returns whether a line is the given --- or ... marker
+func isDocumentMarker(line []byte, marker string) bool {
+	if !bytes.HasPrefix(line, []byte(marker)) {
+		return false
+	}
+	rest := line[len(marker):]
+	return len(rest) == 0 || rest[0] == ' ' || rest[0] == '\t' || rest[0] == '\r' || rest[0] == '\n'
+}
+
+// hasInlineContent returns whether a --- marker line is followed by
+// content on the same line, such as a block scalar indicator or a flow
+// collection, rather than just whitespace or a comment
+func hasInlineContent(marker []byte) bool {
+	return !isBlankOrComment(marker[3:])
+}
+
+// isBlankOrComment returns whether a line holds only whitespace or a comment
+func isBlankOrComment(line []byte) bool {
+	trimmed := bytes.TrimSpace(line)
+	return len(trimmed) == 0 || trimmed[0] == '#'
+}
@@ -0,0 +1,316 @@
+package kubeval
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	yamlv3 "gopkg.in/yaml.v3"
+)
+
+func TestDecodeDocuments(t *testing.T) {
+	var tests = []struct {
+		Input         string
+		ExpectedLines []int
+	}{
+		{
+			Input:         "",
+			ExpectedLines: nil,
+		},
+		{
+			Input:         "# just a comment\n",
+			ExpectedLines: []int{1},
+		},
+		{
+			Input:         "a: 1\n---\n---\nb: 2\n",
+			ExpectedLines: []int{1, 2, 3},
+		},
+		{
+			Input:         "%YAML 1.2\n---\na: 1\n",
+			ExpectedLines: []int{1},
+		},
+		{
+			Input:         "--- |\n  text\n--- {a: 1}\n",
+			ExpectedLines: []int{1, 3},
+		},
+		{
+			Input:         "a: 1\n----\n---b\n",
+			ExpectedLines: []int{1},
+		},
+		{
+			Input:         "\xef\xbb\xbf---\na: 1\n---\nb: 2",
+			ExpectedLines: []int{1, 3},
+		},
+	}
+	for _, test := range tests {
+		documents := decodeDocuments([]byte(test.Input))
+		var lines []int
+		for _, document := range documents {
+			lines = append(lines, document.line)
+		}
+		if len(lines) != len(test.ExpectedLines) {
+			t.Errorf("Expected documents at lines %v in %q, got %v", test.ExpectedLines, test.Input, lines)
+			continue
+		}
+		for i := range lines {
+			if lines[i] != test.ExpectedLines[i] {
+				t.Errorf("Expected documents at lines %v in %q, got %v", test.ExpectedLines, test.Input, lines)
+				break
+			}
+		}
+	}
+}
+
+// The documents are split without parsing them, but are the same as those
+// read by yaml.v3's Decoder
+func TestDecodeDocumentsMatchYAMLDecoder(t *testing.T) {
+	var tests = []struct {
+		Name  string
+		Input string
+	}{
+		{"byte order mark before a marker", "\xef\xbb\xbf---\na: 1\n---\nb: 2\n"},
+		{"byte order mark before content", "\xef\xbb\xbfa: 1\n---\nb: 2\n"},
+		{"crlf", "a: 1\r\n---\r\nb: 2\r\n...\r\n---\r\nc: 3\r\n"},
+		{"comment after a marker", "a: 1\n--- # second\nb: 2\n---\t# third\nc: 3\n"},
+		{"markers in block scalars", "a: |\n  ---\n  ...\n  --- b\nc: >-\n  ---\n---\nd: 1\n"},
+		{"marker starting a block scalar", "--- |\n  ---\n  text\n--- >\n  more\n"},
+		{"marker-like content", "a: 1\n----: 2\n---b: 3\n...c: 4\n"},
+		{"empty document", "a: 1\n---\n---\nb: 2\n"},
+		{"document end", "a: 1\n...\n# comment\n---\nb: 2\n...\n"},
+	}
+	for _, test := range tests {
+		var expected []interface{}
+		decoder := yamlv3.NewDecoder(strings.NewReader(test.Input))
+		for {
+			var value interface{}
+			err := decoder.Decode(&value)
+			if err == io.EOF {
+				break
+			} else if err != nil {
+				t.Fatalf("%s: unexpected error from yaml.v3: %s", test.Name, err.Error())
+			}
+			expected = append(expected, value)
+		}
+
+		var actual []interface{}
+		for _, document := range decodeDocuments([]byte(test.Input)) {
+			var value interface{}
+			if err := yamlv3.Unmarshal(document.data, &value); err != nil {
+				t.Errorf("%s: unexpected error decoding %q: %s", test.Name, document.data, err.Error())
+			}
+			actual = append(actual, value)
+		}
+		assert.Equal(t, expected, actual, test.Name)
+	}
+}
+
+// Unlike yaml.v3's Decoder, which stops at the first syntax error, every
+// document after a broken one is still read
+func TestDecodeDocumentsAfterSyntaxError(t *testing.T) {
+	input := "a: [1\n---\nb: 2\n---\nc: 3\n"
+	documents := decodeDocuments([]byte(input))
+	if len(documents) != 3 {
+		t.Fatalf("Expected 3 documents, got %d", len(documents))
+	}
+	var value map[string]interface{}
+	if err := yamlv3.Unmarshal(documents[2].data, &value); err != nil || value["c"] != 3 {
+		t.Errorf("Expected the last document to be read, got %v: %v", value, err)
+	}
+}
+
+func TestDecodeDocumentFixtures(t *testing.T) {
+	var tests = []struct {
+		Fixture       string
+		ExpectedLines []int
+	}{
+		{
+			Fixture:       "stream_separator_comments.yaml",
+			ExpectedLines: []int{1, 18, 36},
+		},
+		{
+			Fixture:       "stream_document_end.yaml",
+			ExpectedLines: []int{1, 19, 39},
+		},
+		{
+			Fixture:       "stream_leading_trailing_markers.yaml",
+			ExpectedLines: []int{1, 19},
+		},
+		{
+			Fixture:       "stream_crlf.yaml",
+			ExpectedLines: []int{1, 19},
+		},
+		{
+			Fixture:       "stream_block_scalar.yaml",
+			ExpectedLines: []int{1, 26},
+		},
+	}
+	for _, test := range tests {
+		filePath, _ := filepath.Abs("../fixtures/" + test.Fixture)
+		fileContents, _ := ioutil.ReadFile(filePath)
+		documents := decodeDocuments(fileContents)
+		if len(documents) != len(test.ExpectedLines) {
+			t.Errorf("Expected %d documents in %s, got %d", len(test.ExpectedLines), test.Fixture, len(documents))
+			continue
+		}
+		for i, document := range documents {
+			if document.line != test.ExpectedLines[i] {
+				t.Errorf("Expected document %d of %s to start at line %d, got %d", i, test.Fixture, test.ExpectedLines[i], document.line)
+			}
+			if !bytes.Equal(fileContents[document.offset:document.offset+len(document.data)], document.data) {
+				t.Errorf("Expected document %d of %s to be found at offset %d", i, test.Fixture, document.offset)
+			}
+			if bytes.Count(document.data, []byte("kind: ReplicationController")) != 1 {
+				t.Errorf("Expected document %d of %s to hold one resource, got:\n%s", i, test.Fixture, document.data)
+			}
+		}
+	}
+}
+
+func TestValidateDocumentFixtures(t *testing.T) {
+	var tests = []struct {
+		Fixture       string
+		ExpectedNames []string
+	}{
+		{
+			Fixture:       "stream_separator_comments.yaml",
+			ExpectedNames: []string{"first", "second", "third"},
+		},
+		{
+			Fixture:       "stream_document_end.yaml",
+			ExpectedNames: []string{"first", "second", "third"},
+		},
+		{
+			Fixture:       "stream_leading_trailing_markers.yaml",
+			ExpectedNames: []string{"first", "second"},
+		},
+		{
+			Fixture:       "stream_crlf.yaml",
+			ExpectedNames: []string{"first", "second"},
+		},
+		{
+			Fixture:       "stream_block_scalar.yaml",
+			ExpectedNames: []string{"first", "second"},
+		},
+	}
+	schemaLocation, _ := filepath.Abs("../fixtures/schemas")
+	for _, test := range tests {
+		config := NewDefaultConfig()
+		config.FileName = test.Fixture
+		config.SchemaLocation = "file://" + schemaLocation
+		filePath, _ := filepath.Abs("../fixtures/" + test.Fixture)
+		fileContents, _ := ioutil.ReadFile(filePath)
+		results, err := Validate(fileContents, config)
+		if err != nil {
+			t.Errorf("Unexpected error for %s: %s", test.Fixture, err.Error())
+			continue
+		}
+		if len(results) != len(test.ExpectedNames) {
+			t.Errorf("Expected %d results for %s, got %d", len(test.ExpectedNames), test.Fixture, len(results))
+			continue
+		}
+		for i, result := range results {
+			if result.ResourceName != test.ExpectedNames[i] || len(result.Errors) != 0 {
+				t.Errorf("Expected a valid %s in %s, got %s with errors %v", test.ExpectedNames[i], test.Fixture, result.ResourceName, result.Errors)
+			}
+		}
+	}
+}
+
+// documentReader returns one line of a stream at a time, and records how
+// many lines have been read so far
+type documentReader struct {
+	lines [][]byte
+	read  int
+}
+
+func (r *documentReader) Read(p []byte) (int, error) {
+	if r.read == len(r.lines) {
+		return 0, io.EOF
+	}
+	n := copy(p, r.lines[r.read])
+	r.lines[r.read] = r.lines[r.read][n:]
+	if len(r.lines[r.read]) == 0 {
+		r.read++
+	}
+	return n, nil
+}
+
+func TestValidateStream(t *testing.T) {
+	filePath, _ := filepath.Abs("../fixtures/stream_separator_comments.yaml")
+	fileContents, _ := ioutil.ReadFile(filePath)
+	reader := &documentReader{lines: bytes.SplitAfter(fileContents, []byte("\n"))}
+
+	schemaLocation, _ := filepath.Abs("../fixtures/schemas")
+	config := NewDefaultConfig()
+	config.FileName = "stream_separator_comments.yaml"
+	config.SchemaLocation = "file://" + schemaLocation
+
+	var names []string
+	var linesRead []int
+	err := ValidateStream(context.Background(), reader, NewSharedSchemaCache(), func(result ValidationResult) error {
+		if len(result.Errors) > 0 {
+			t.Errorf("Unexpected errors for %s: %v", result.ResourceName, result.Errors)
+		}
+		names = append(names, result.ResourceName)
+		linesRead = append(linesRead, reader.read)
+		return nil
+	}, config)
+	if err != nil {
+		t.Errorf("Unexpected error: %s", err.Error())
+	}
+	expected := []string{"first", "second", "third"}
+	if len(names) != len(expected) {
+		t.Fatalf("Expected results for %v, got %v", expected, names)
+	}
+	for i := range names {
+		if names[i] != expected[i] {
+			t.Errorf("Expected results for %v, got %v", expected, names)
+		}
+	}
+	// Every document but the last is reported once the marker after it
+	// has been read, before the rest of the stream
+	if linesRead[0] != 18 || linesRead[1] != 36 {
+		t.Errorf("Expected results to be reported as the stream was read, got them after lines %v", linesRead)
+	}
+}
+
+func TestValidateStreamStops(t *testing.T) {
+	input := []byte("kind: Foo\napiVersion: v1\n---\nkind: Bar\napiVersion: v1\n")
+	config := NewDefaultConfig()
+	config.IgnoreMissingSchemas = true
+
+	stop := errors.New("stop")
+	count := 0
+	err := ValidateStream(context.Background(), bytes.NewReader(input), NewSharedSchemaCache(), func(result ValidationResult) error {
+		count++
+		return stop
+	}, config)
+	if err != stop || count != 1 {
+		t.Errorf("Expected the handler's error after one result, got %v after %d", err, count)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = ValidateStream(ctx, bytes.NewReader(input), NewSharedSchemaCache(), func(result ValidationResult) error {
+		t.Errorf("Unexpected result for %s after cancellation", result.Kind)
+		return nil
+	}, config)
+	if err != context.Canceled {
+		t.Errorf("Expected %v, got %v", context.Canceled, err)
+	}
+
+	count = 0
+	err = ValidateStream(context.Background(), bytes.NewReader(nil), NewSharedSchemaCache(), func(result ValidationResult) error {
+		count++
+		return nil
+	}, config)
+	if err != nil || count != 1 {
+		t.Errorf("Expected one empty result for an empty stream, got %d and %v", count, err)
+	}
+}
@@ -0,0 +1,54 @@
+package kubeval
+
+import (
+	"fmt"
+	"sync"
+)
+
+// ResourceLocation is where in the input a resource was defined
+type ResourceLocation struct {
+	FileName string
+	// Line is the line the resource starts on, or 0 if it isn't known
+	Line     int
+	ListPath ListPath
+}
+
+func (l ResourceLocation) String() string {
+	location := l.FileName
+	if l.Line > 0 {
+		location = fmt.Sprintf("%s:%d", location, l.Line)
+	}
+	if len(l.ListPath) > 0 {
+		location = fmt.Sprintf("%s (in %s)", location, describeListPath(l.ListPath))
+	}
+	return location
+}
+
+// DuplicateTracker records the resources validated across any number of
+// inputs, so that a resource defined more than once can be reported with
+// both of its locations. It is safe for use by multiple goroutines.
+type DuplicateTracker struct {
+	mu   sync.Mutex
+	seen map[[4]string]ResourceLocation // keyed by [API version, kind, namespace, name]
+}
+
+// NewDuplicateTracker returns a tracker which hasn't seen any resources
+func NewDuplicateTracker() *DuplicateTracker {
+	return &DuplicateTracker{
+		seen: make(map[[4]string]ResourceLocation),
+	}
+}
+
+// Track records the location of a resource. If a resource with the same
+// API version, kind, namespace and name was tracked before, the location
+// it was first tracked at is returned along with true.
+func (d *DuplicateTracker) Track(apiVersion, kind, namespace, name string, location ResourceLocation) (ResourceLocation, bool) {
+	key := [4]string{apiVersion, kind, namespace, name}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if first, ok := d.seen[key]; ok {
+		return first, true
+	}
+	d.seen[key] = location
+	return location, false
+}
@@ -0,0 +1,57 @@
+package kubeval
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDuplicateTrackerAcrossInputs(t *testing.T) {
+	first := []byte(`apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+`)
+	second := []byte(`---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  namespace: default
+`)
+	config := NewDefaultConfig()
+	config.IgnoreMissingSchemas = true
+
+	// Without a tracker, each input is only checked against itself
+	for _, input := range [][]byte{first, second} {
+		if _, err := Validate(input, config); err != nil {
+			t.Errorf("Unexpected error without a tracker: %s", err.Error())
+		}
+	}
+
+	config.DuplicateTracker = NewDuplicateTracker()
+	config.FileName = "first.yaml"
+	if _, err := Validate(first, config); err != nil {
+		t.Fatalf("Unexpected error for the first input: %s", err.Error())
+	}
+	config.FileName = "second.yaml"
+	_, err := Validate(second, config)
+	expected := "second.yaml: Duplicate 'Deployment' resource 'web' in namespace 'default' at second.yaml:2, first defined at first.yaml:1"
+	if err == nil || !strings.Contains(err.Error(), expected) {
+		t.Errorf("Expected %q, got %v", expected, err)
+	}
+}
+
+func TestDuplicateTrackerTrack(t *testing.T) {
+	tracker := NewDuplicateTracker()
+	location := ResourceLocation{FileName: "a.yaml", Line: 3}
+	if _, found := tracker.Track("v1", "Service", "default", "web", location); found {
+		t.Errorf("Expected the first resource not to be a duplicate")
+	}
+	if _, found := tracker.Track("v1", "Service", "other", "web", ResourceLocation{FileName: "b.yaml"}); found {
+		t.Errorf("Expected a resource in another namespace not to be a duplicate")
+	}
+	first, found := tracker.Track("v1", "Service", "default", "web", ResourceLocation{FileName: "c.yaml"})
+	if !found || first.String() != "a.yaml:3" {
+		t.Errorf("Expected a duplicate of a.yaml:3, got %v at %s", found, first)
+	}
+}
@@ -0,0 +1,106 @@
+package kubeval
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+
+	multierror "github.com/hashicorp/go-multierror"
+)
+
+// ErrorCategory is the kind of problem a ValidationError describes
+type ErrorCategory string
+
+const (
+	// ParseError is for input which couldn't be read, or decoded into a
+	// Kubernetes resource
+	ParseError ErrorCategory = "parse-error"
+	// MissingSchema is for resources for which no schema could be found
+	MissingSchema ErrorCategory = "missing-schema"
+	// DuplicateResource is for resources defined more than once
+	DuplicateResource ErrorCategory = "duplicate-resource"
+	// ProhibitedKind is for resources of one of the KindsToReject
+	ProhibitedKind ErrorCategory = "prohibited-kind"
+)
+
+// ValidationError is one of the errors returned by ValidateWithCache and
+// the other Validate functions, for problems which stop a resource being
+// validated rather than making it invalid. Its message is the same as that
+// of the error it wraps.
+type ValidationError struct {
+	Category ErrorCategory
+	// FileName is the file holding the resource the error is about
+	FileName string
+	// Position is where the problem is in the input, or where the resource
+	// starts, or the zero Position if neither is known
+	Position Position
+	Err      error
+}
+
+func (e *ValidationError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the error which the ValidationError wraps
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
+
+// newValidationError returns a ValidationError of the given category for
+// an error with a formatted message
+func newValidationError(category ErrorCategory, format string, a ...interface{}) *ValidationError {
+	return &ValidationError{Category: category, Err: fmt.Errorf(format, a...)}
+}
+
+// asValidationError returns err as a ValidationError, making it a
+// ParseError if it isn't one already
+func asValidationError(err error) *ValidationError {
+	if validationErr, ok := err.(*ValidationError); ok {
+		return validationErr
+	}
+	return &ValidationError{Category: ParseError, Err: err}
+}
+
+// validationErrors splits an error returned for a file, which may hold
+// several, into ValidationErrors, taking those which don't say which file
+// they're about to be about fileName
+func validationErrors(fileName string, err error) []*ValidationError {
+	errs := []error{err}
+	if merr, ok := err.(*multierror.Error); ok {
+		errs = merr.Errors
+	}
+	validationErrs := make([]*ValidationError, len(errs))
+	for i, err := range errs {
+		validationErr := asValidationError(err)
+		if validationErr.FileName == "" {
+			validationErr.FileName = fileName
+		}
+		validationErrs[i] = validationErr
+	}
+	return validationErrs
+}
+
+// yamlErrorLinePattern finds the line a YAML decoding error is on
+var yamlErrorLinePattern = regexp.MustCompile(`\bline (\d+)\b`)
+
+// locateValidationError records the file and position of an error about
+// a resource which starts at the given line, and which was read from a
+// document starting at documentLine. The position of a decoding error is
+// that given in its message, where there is one.
+func locateValidationError(err *ValidationError, fileName string, line int, documentLine int) {
+	err.FileName = fileName
+	if err.Position.IsValid() {
+		return
+	}
+	if err.Category == ParseError {
+		if found := yamlErrorLinePattern.FindStringSubmatch(err.Err.Error()); found != nil {
+			if errorLine, convErr := strconv.Atoi(found[1]); convErr == nil && errorLine > 0 {
+				err.Position = Position{Line: documentLine + errorLine - 1}
+				return
+			}
+		}
+	}
+	if line > 0 {
+		err.Position = Position{Line: line, Column: 1}
+	}
+}
@@ -0,0 +1,62 @@
+package kubeval
+
+import (
+	"path/filepath"
+	"testing"
+
+	multierror "github.com/hashicorp/go-multierror"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidationErrors(t *testing.T) {
+	input := []byte(`apiVersion: v1
+kind: ReplicationController
+metadata:
+  name: web
+---
+apiVersion: v1
+kind: ReplicationController
+metadata:
+  name: web
+---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: token
+---
+apiVersion: v1
+kind: ConfigMap
+data:
+  key: [
+`)
+	schemaLocation, _ := filepath.Abs("../fixtures/schemas")
+	config := NewDefaultConfig()
+	config.FileName = "errors.yaml"
+	config.SchemaLocation = "file://" + schemaLocation
+	config.KindsToReject = []string{"Secret"}
+	_, err := Validate(input, config)
+	merr, ok := err.(*multierror.Error)
+	if !ok {
+		t.Fatalf("Expected a multierror, got %v", err)
+	}
+
+	type expectedError struct {
+		Category ErrorCategory
+		FileName string
+		Position Position
+	}
+	var found []expectedError
+	for _, e := range merr.Errors {
+		validationErr, ok := e.(*ValidationError)
+		if !ok {
+			t.Fatalf("Expected a ValidationError, got %v", e)
+		}
+		found = append(found, expectedError{validationErr.Category, validationErr.FileName, validationErr.Position})
+	}
+	assert.Equal(t, []expectedError{
+		{DuplicateResource, "errors.yaml", Position{Line: 6, Column: 1}},
+		{ProhibitedKind, "errors.yaml", Position{Line: 11, Column: 1}},
+		{ParseError, "errors.yaml", Position{Line: 19}},
+	}, found)
+	assert.Equal(t, "Prohibited resource kind 'Secret' in errors.yaml", merr.Errors[1].Error())
+}
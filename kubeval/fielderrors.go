@@ -0,0 +1,220 @@
+package kubeval
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/xeipuuv/gojsonschema"
+)
+
+// The codes of FieldErrors, which are the same as the types of field error
+// returned by the API server
+const (
+	FieldValueRequired     = "FieldValueRequired"
+	FieldValueTypeInvalid  = "FieldValueTypeInvalid"
+	FieldValueNotSupported = "FieldValueNotSupported"
+	FieldValueForbidden    = "FieldValueForbidden"
+	FieldValueDuplicate    = "FieldValueDuplicate"
+	FieldValueTooLong      = "FieldValueTooLong"
+	FieldValueTooMany      = "FieldValueTooMany"
+	FieldValueInvalid      = "FieldValueInvalid"
+	InternalError          = "InternalError"
+)
+
+// fieldErrorCodes maps the types of gojsonschema errors, and those
+// reported by kubeval itself, to codes. Any other type is
+// FieldValueInvalid.
+var fieldErrorCodes = map[string]string{
+	"required":                        FieldValueRequired,
+	"missing_dependency":              FieldValueRequired,
+	"invalid_type":                    FieldValueTypeInvalid,
+	"enum":                            FieldValueNotSupported,
+	"const":                           FieldValueNotSupported,
+	"additional_property_not_allowed": FieldValueForbidden,
+	"invalid_property_name":           FieldValueForbidden,
+	"invalid_property_pattern":        FieldValueForbidden,
+	"unique":                          FieldValueDuplicate,
+	"duplicate_key":                   FieldValueDuplicate,
+	"string_lte":                      FieldValueTooLong,
+	"array_max_items":                 FieldValueTooMany,
+	"array_max_properties":            FieldValueTooMany,
+	"internal":                        InternalError,
+}
+
+// fieldErrorSummaries begin the message of an error with each code, as
+// they do in kubectl
+var fieldErrorSummaries = map[string]string{
+	FieldValueRequired:     "Required value",
+	FieldValueTypeInvalid:  "Invalid value",
+	FieldValueNotSupported: "Unsupported value",
+	FieldValueForbidden:    "Forbidden",
+	FieldValueDuplicate:    "Duplicate value",
+	FieldValueTooLong:      "Too long",
+	FieldValueTooMany:      "Too many",
+	FieldValueInvalid:      "Invalid value",
+	InternalError:          "Internal error",
+}
+
+// FieldError is a validation error described in the same way as kubectl
+// describes the errors returned by the API server
+type FieldError struct {
+	// Field is the path to the value the error is about, such as
+	// spec.template.spec.containers[0].image, and is empty for errors
+	// about the resource as a whole
+	Field string `json:"field"`
+	// Code identifies the kind of error, such as FieldValueRequired
+	Code string `json:"code"`
+	// Message describes the error, without the field
+	Message string `json:"message"`
+	// Suggestion is the path to the field which was probably meant, for
+	// errors about an unknown field, if there is one like it
+	Suggestion string `json:"suggestion,omitempty"`
+}
+
+func (e FieldError) String() string {
+	description := e.Message
+	if e.Field != "" {
+		description = e.Field + ": " + description
+	}
+	if e.Suggestion != "" {
+		description += "; did you mean " + e.Suggestion + "?"
+	}
+	return description
+}
+
+// NewFieldError describes an error found by validating a resource with a
+// kubectl style field path, message and code
+func NewFieldError(err gojsonschema.ResultError) FieldError {
+	code, ok := fieldErrorCodes[err.Type()]
+	if !ok {
+		code = FieldValueInvalid
+	}
+	path := errorPath(err)
+	details := err.Details()
+
+	var value, detail string
+	switch err.Type() {
+	case "required", "additional_property_not_allowed":
+		if property, ok := details["property"].(string); ok {
+			path = append(path, property)
+		}
+	}
+	switch err.Type() {
+	case "required":
+	case "additional_property_not_allowed":
+		detail = "unknown field"
+	case "invalid_type":
+		value = describeValue(err.Value())
+		detail = fmt.Sprintf("expected %s, given %s", describeTypes(details["expected"]), details["given"])
+	case "enum":
+		value = describeValue(err.Value())
+		detail = fmt.Sprintf("supported values: %v", details["allowed"])
+	case "const":
+		value = describeValue(err.Value())
+		detail = fmt.Sprintf("supported value: %v", details["allowed"])
+	case "format":
+		value = describeValue(err.Value())
+		detail = fmt.Sprintf("must be a valid %v", details["format"])
+	case "pattern":
+		value = describeValue(err.Value())
+		detail = fmt.Sprintf("must match the pattern '%v'", details["pattern"])
+	case "string_gte":
+		value = describeValue(err.Value())
+		detail = fmt.Sprintf("must have at least %v characters", details["min"])
+	case "string_lte":
+		detail = fmt.Sprintf("must have at most %v characters", details["max"])
+	case "array_max_items":
+		detail = fmt.Sprintf("must have at most %v items", details["max"])
+	case "array_max_properties":
+		detail = fmt.Sprintf("must have at most %v properties", details["max"])
+	case "multiple_of", "number_gte", "number_gt", "number_lte", "number_lt":
+		value = describeValue(err.Value())
+		detail = lowerFirst(err.Description())
+	case "missing_dependency", "invalid_property_name", "invalid_property_pattern", "unique",
+		"number_any_of", "number_one_of", "number_all_of", "number_not", "internal",
+		"array_min_items", "array_min_properties", "array_no_additional_items", "contains":
+		detail = lowerFirst(err.Description())
+	default:
+		// Errors reported by kubeval itself, such as those of --check-yaml,
+		// are already worded for people
+		return FieldError{Field: formatFieldPath(path), Code: code, Message: err.Description()}
+	}
+
+	message := fieldErrorSummaries[code]
+	for _, part := range []string{value, detail} {
+		if part != "" {
+			message += ": " + part
+		}
+	}
+	return FieldError{Field: formatFieldPath(path), Code: code, Message: message}
+}
+
+// newFieldErrors describes each of errors with NewFieldError
+func newFieldErrors(errors []gojsonschema.ResultError) []FieldError {
+	if len(errors) == 0 {
+		return nil
+	}
+	fieldErrors := make([]FieldError, len(errors))
+	for i, err := range errors {
+		fieldErrors[i] = NewFieldError(err)
+	}
+	return fieldErrors
+}
+
+// plainFieldPattern matches the fields which kubectl writes after a dot,
+// rather than in brackets
+var plainFieldPattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// formatFieldPath writes the fields leading to a value as kubectl does,
+// with indexes and keys which aren't plain in brackets, as in
+// spec.containers[0].image or metadata.labels[app.kubernetes.io/name]
+func formatFieldPath(path []string) string {
+	var b strings.Builder
+	for _, field := range path {
+		if _, err := strconv.Atoi(field); err == nil || !plainFieldPattern.MatchString(field) {
+			b.WriteString("[" + field + "]")
+			continue
+		}
+		if b.Len() > 0 {
+			b.WriteString(".")
+		}
+		b.WriteString(field)
+	}
+	return b.String()
+}
+
+// describeTypes turns the types expected by a schema, such as
+// [integer,null], into words
+func describeTypes(types interface{}) string {
+	s := strings.Trim(fmt.Sprint(types), "[]")
+	return strings.Replace(s, ",", " or ", -1)
+}
+
+// describeValue writes a value which an error is about in the same way as
+// kubectl, leaving out objects and arrays
+func describeValue(value interface{}) string {
+	switch typed := value.(type) {
+	case nil:
+		return "null"
+	case string:
+		return strconv.Quote(typed)
+	case bool, json.Number, int, int64, float64:
+		return fmt.Sprint(typed)
+	}
+	return ""
+}
+
+// lowerFirst lowercases the first letter of s, so that the descriptions
+// of gojsonschema read as details following a summary
+func lowerFirst(s string) string {
+	r, size := utf8.DecodeRuneInString(s)
+	if r == utf8.RuneError {
+		return s
+	}
+	return string(unicode.ToLower(r)) + s[size:]
+}
@@ -0,0 +1,96 @@
+package kubeval
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xeipuuv/gojsonschema"
+)
+
+func newTypedResultError(errorType string, path []string, value interface{}, details gojsonschema.ErrorDetails, description string) gojsonschema.ResultError {
+	err := &gojsonschema.ResultErrorFields{}
+	err.SetType(errorType)
+	err.SetContext(pathContext(path))
+	err.SetValue(value)
+	err.SetDetails(details)
+	err.SetDescription(description)
+	return err
+}
+
+func TestNewFieldError(t *testing.T) {
+	var tests = []struct {
+		Name     string
+		Err      gojsonschema.ResultError
+		Expected FieldError
+	}{
+		{
+			"additional property",
+			newTypedResultError("additional_property_not_allowed", []string{"spec", "template", "spec", "containers", "0"}, "Always",
+				gojsonschema.ErrorDetails{"property": "imagePullPolic"}, "Additional property imagePullPolic is not allowed"),
+			FieldError{"spec.template.spec.containers[0].imagePullPolic", FieldValueForbidden, "Forbidden: unknown field", ""},
+		},
+		{
+			"required",
+			newTypedResultError("required", []string{"spec", "containers", "1"}, map[string]interface{}{},
+				gojsonschema.ErrorDetails{"property": "name"}, "name is required"),
+			FieldError{"spec.containers[1].name", FieldValueRequired, "Required value", ""},
+		},
+		{
+			"invalid type",
+			newTypedResultError("invalid_type", []string{"spec", "replicas"}, "three",
+				gojsonschema.ErrorDetails{"expected": "[integer,null]", "given": "string"}, "Invalid type. Expected: [integer,null], given: string"),
+			FieldError{"spec.replicas", FieldValueTypeInvalid, `Invalid value: "three": expected integer or null, given string`, ""},
+		},
+		{
+			"enum",
+			newTypedResultError("enum", []string{"spec", "type"}, "Other",
+				gojsonschema.ErrorDetails{"allowed": `"ClusterIP", "NodePort"`}, `type must be one of the following: "ClusterIP", "NodePort"`),
+			FieldError{"spec.type", FieldValueNotSupported, `Unsupported value: "Other": supported values: "ClusterIP", "NodePort"`, ""},
+		},
+		{
+			"format",
+			newTypedResultError("format", []string{"spec", "replicas"}, json.Number("99999999999"),
+				gojsonschema.ErrorDetails{"format": "int32"}, "Does not match format 'int32'"),
+			FieldError{"spec.replicas", FieldValueInvalid, "Invalid value: 99999999999: must be a valid int32", ""},
+		},
+		{
+			"minimum",
+			newTypedResultError("number_gte", []string{"spec", "replicas"}, json.Number("-1"),
+				gojsonschema.ErrorDetails{"min": 0}, "Must be greater than or equal to 0"),
+			FieldError{"spec.replicas", FieldValueInvalid, "Invalid value: -1: must be greater than or equal to 0", ""},
+		},
+		{
+			"key with dots",
+			newTypedResultError("invalid_type", []string{"metadata", "labels", "app.kubernetes.io/name"}, json.Number("3"),
+				gojsonschema.ErrorDetails{"expected": "string", "given": "integer"}, "Invalid type. Expected: string, given: integer"),
+			FieldError{"metadata.labels[app.kubernetes.io/name]", FieldValueTypeInvalid, "Invalid value: 3: expected string, given integer", ""},
+		},
+		{
+			"kubeval error",
+			newTypedResultError("duplicate_key", []string{"spec", "replicas"}, "replicas",
+				gojsonschema.ErrorDetails{}, "Duplicate key replicas replaces the value at line 7"),
+			FieldError{"spec.replicas", FieldValueDuplicate, "Duplicate key replicas replaces the value at line 7", ""},
+		},
+	}
+	for _, test := range tests {
+		assert.Equal(t, test.Expected, NewFieldError(test.Err), test.Name)
+	}
+}
+
+func TestValidateFieldErrors(t *testing.T) {
+	fileContents, _ := ioutil.ReadFile("../fixtures/invalid.yaml")
+	schemaLocation, _ := filepath.Abs("../fixtures/schemas")
+	config := NewDefaultConfig()
+	config.FileName = "invalid.yaml"
+	config.SchemaLocation = "file://" + schemaLocation
+	results, err := Validate(fileContents, config)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	expected := []FieldError{{"spec.replicas", FieldValueTypeInvalid, `Invalid value: "asd\"": expected integer or null, given string`, ""}}
+	assert.Equal(t, expected, results[0].FieldErrors)
+	assert.Equal(t, `spec.replicas: Invalid value: "asd\"": expected integer or null, given string (line 6, column 13)`, describeError(results[0], 0))
+}
@@ -0,0 +1,101 @@
+package kubeval
+
+import (
+	"encoding/base64"
+	"math"
+	"math/big"
+	"regexp"
+
+	"github.com/xeipuuv/gojsonschema"
+)
+
+// The formats used by Kubernetes schemas are registered with gojsonschema
+// once, before any schema is compiled, as a schema only checks the
+// formats which are registered when it's compiled
+func init() {
+	gojsonschema.FormatCheckers.Add("int32", IntegerFormat{Min: math.MinInt32, Max: math.MaxInt32})
+	gojsonschema.FormatCheckers.Add("int64", IntegerFormat{Min: math.MinInt64, Max: math.MaxInt64})
+	gojsonschema.FormatCheckers.Add("byte", ByteFormat{})
+	gojsonschema.FormatCheckers.Add("int-or-string", IntOrStringFormat{})
+	gojsonschema.FormatCheckers.Add("quantity", QuantityFormat{})
+}
+
+// lenientFormats are the formats which are only checked with
+// StrictFormats, as manifests which the API server accepts may still be
+// reported as invalid
+var lenientFormats = []string{"byte", "quantity"}
+
+// IntegerFormat checks that numbers are integers between Min and Max
+type IntegerFormat struct {
+	Min int64
+	Max int64
+}
+
+// IsFormat returns whether the input is an integer in range, or isn't a
+// number at all
+func (f IntegerFormat) IsFormat(input interface{}) bool {
+	number, ok := input.(*big.Float)
+	if !ok {
+		return true
+	}
+	if !number.IsInt() {
+		return false
+	}
+	value, accuracy := number.Int64()
+	return accuracy == big.Exact && value >= f.Min && value <= f.Max
+}
+
+// ByteFormat checks that strings are base64 encoded, as the API server
+// requires of fields such as the data of a Secret
+type ByteFormat struct{}
+
+// IsFormat returns whether the input is valid base64, or isn't a string
+func (f ByteFormat) IsFormat(input interface{}) bool {
+	value, ok := input.(string)
+	if !ok {
+		return true
+	}
+	_, err := base64.StdEncoding.DecodeString(value)
+	return err == nil
+}
+
+// IntOrStringFormat checks that values which may be an integer or a
+// string, such as the port of a probe, are not any other kind of number
+type IntOrStringFormat struct{}
+
+// IsFormat returns whether the input is a string or a 32 bit integer
+func (f IntOrStringFormat) IsFormat(input interface{}) bool {
+	return IntegerFormat{Min: math.MinInt32, Max: math.MaxInt32}.IsFormat(input)
+}
+
+// quantityPattern is the grammar of a Kubernetes resource quantity, such
+// as 100m, 1.5Gi or 1e3
+var quantityPattern = regexp.MustCompile(`^[+-]?(\d+(\.\d*)?|\.\d+)([KMGTPE]i|[numkMGTPE]|[eE][+-]?\d+)?$`)
+
+// QuantityFormat checks that strings are Kubernetes resource quantities
+type QuantityFormat struct{}
+
+// IsFormat returns whether the input is a quantity, or isn't a string
+func (f QuantityFormat) IsFormat(input interface{}) bool {
+	value, ok := input.(string)
+	if !ok {
+		return true
+	}
+	return quantityPattern.MatchString(value)
+}
+
+// filterFormatErrors removes the errors for formats which are only
+// checked with StrictFormats
+func filterFormatErrors(errors []gojsonschema.ResultError, config *Config) []gojsonschema.ResultError {
+	if config.StrictFormats {
+		return errors
+	}
+	filtered := make([]gojsonschema.ResultError, 0, len(errors))
+	for _, err := range errors {
+		if format, ok := err.Details()["format"].(string); ok && err.Type() == "format" && in(lenientFormats, format) {
+			continue
+		}
+		filtered = append(filtered, err)
+	}
+	return filtered
+}
@@ -0,0 +1,148 @@
+package kubeval
+
+import (
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xeipuuv/gojsonschema"
+)
+
+func TestFormatCheckers(t *testing.T) {
+	var tests = []struct {
+		Format   string
+		Input    interface{}
+		Expected bool
+	}{
+		{Format: "int32", Input: big.NewFloat(3), Expected: true},
+		{Format: "int32", Input: big.NewFloat(-2147483648), Expected: true},
+		{Format: "int32", Input: big.NewFloat(2147483648), Expected: false},
+		{Format: "int32", Input: big.NewFloat(1.5), Expected: false},
+		{Format: "int32", Input: "not a number", Expected: true},
+		{Format: "int64", Input: big.NewFloat(99999999999), Expected: true},
+		{Format: "int64", Input: new(big.Float).SetInt(new(big.Int).Lsh(big.NewInt(1), 63)), Expected: false},
+		{Format: "byte", Input: "aGVsbG8=", Expected: true},
+		{Format: "byte", Input: "aGVs\nbG8=", Expected: true},
+		{Format: "byte", Input: "hello!", Expected: false},
+		{Format: "int-or-string", Input: "http", Expected: true},
+		{Format: "int-or-string", Input: big.NewFloat(8080), Expected: true},
+		{Format: "int-or-string", Input: big.NewFloat(80.5), Expected: false},
+		{Format: "quantity", Input: "100m", Expected: true},
+		{Format: "quantity", Input: "1.5Gi", Expected: true},
+		{Format: "quantity", Input: "1e3", Expected: true},
+		{Format: "quantity", Input: ".5", Expected: true},
+		{Format: "quantity", Input: "-2k", Expected: true},
+		{Format: "quantity", Input: big.NewFloat(0.5), Expected: true},
+		{Format: "quantity", Input: "two", Expected: false},
+		{Format: "quantity", Input: "1.5 Gi", Expected: false},
+		{Format: "quantity", Input: "1Gb", Expected: false},
+	}
+	for _, test := range tests {
+		if actual := gojsonschema.FormatCheckers.IsFormat(test.Format, test.Input); actual != test.Expected {
+			t.Errorf("Expected %v for %v in format %s, got %v", test.Expected, test.Input, test.Format, actual)
+		}
+	}
+}
+
+func TestValidateIntegerFormat(t *testing.T) {
+	input := []byte(`apiVersion: v1
+kind: ReplicationController
+metadata:
+  name: large
+spec:
+  replicas: 99999999999
+`)
+	schemaLocation, _ := filepath.Abs("../fixtures/schemas")
+	config := NewDefaultConfig()
+	config.SchemaLocation = "file://" + schemaLocation
+	results, err := Validate(input, config)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	if len(results[0].Errors) != 1 || results[0].Errors[0].Type() != "format" || results[0].Errors[0].Field() != "spec.replicas" {
+		t.Errorf("Expected replicas to be out of range, got %v", results[0].Errors)
+	}
+}
+
+func TestValidateStrictFormats(t *testing.T) {
+	input := []byte(`apiVersion: v1
+kind: Secret
+metadata:
+  name: secret
+data:
+  password: not base64!
+---
+apiVersion: v1
+kind: LimitRange
+metadata:
+  name: limits
+spec:
+  limits:
+  - type: Container
+    default:
+      cpu: two
+      memory: 512Mi
+    defaultRequest:
+      memory: 256000
+`)
+	server := httptest.NewServer(http.FileServer(http.Dir("../fixtures/schemas")))
+	defer server.Close()
+	schemaLocation, _ := filepath.Abs("../fixtures/schemas")
+
+	// The published schemas only describe a quantity as a string or a
+	// number, so the format is found from its shape
+	for _, location := range []string{server.URL, "file://" + schemaLocation} {
+		config := NewDefaultConfig()
+		config.SchemaLocation = location
+		config.NoCache = true
+		results, err := Validate(input, config)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %s", location, err.Error())
+		}
+		for _, result := range results {
+			if !result.ValidatedAgainstSchema || len(result.Errors) != 0 {
+				t.Errorf("%s: expected byte and quantity formats not to be checked by default, got %v", location, result.Errors)
+			}
+		}
+
+		config.StrictFormats = true
+		results, err = Validate(input, config)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %s", location, err.Error())
+		}
+		var fields []string
+		for _, result := range results {
+			for _, e := range result.Errors {
+				assert.Equal(t, "format", e.Type(), location)
+				fields = append(fields, e.Field())
+			}
+		}
+		assert.Equal(t, []string{"data", "spec.limits.0.default"}, fields, location)
+	}
+}
+
+func TestMarkQuantities(t *testing.T) {
+	var tests = []struct {
+		Name     string
+		Schema   string
+		Expected interface{}
+	}{
+		{"quantity", `{"oneOf": [{"type": ["string", "null"]}, {"type": "number"}]}`, "quantity"},
+		{"reversed", `{"oneOf": [{"type": "number"}, {"type": "string"}]}`, "quantity"},
+		{"int-or-string", `{"oneOf": [{"type": "string"}, {"type": "integer"}]}`, nil},
+		{"existing format", `{"oneOf": [{"type": "string"}, {"type": "number"}], "format": "other"}`, "other"},
+		{"several types", `{"oneOf": [{"type": ["string", "boolean"]}, {"type": "number"}]}`, nil},
+	}
+	for _, test := range tests {
+		document, err := gojsonschema.NewStringLoader(`{"properties": {"value": ` + test.Schema + `}}`).LoadJSON()
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %s", test.Name, err.Error())
+		}
+		markQuantities(document)
+		value := document.(map[string]interface{})["properties"].(map[string]interface{})["value"].(map[string]interface{})
+		assert.Equal(t, test.Expected, value["format"], test.Name)
+	}
+}
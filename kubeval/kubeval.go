@@ -2,14 +2,18 @@ package kubeval
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
 	"fmt"
+	"io"
 	"os"
 	"regexp"
 	"strings"
+	"sync"
+	"text/template"
 
 	"github.com/hashicorp/go-multierror"
 	"github.com/xeipuuv/gojsonschema"
-	"sigs.k8s.io/yaml"
 )
 
 // ValidFormat is a type for quickly forcing
@@ -32,11 +36,45 @@ type ValidationResult struct {
 	Errors                 []gojsonschema.ResultError
 	ResourceName           string
 	ResourceNamespace      string
+	// DocumentIndex is the 0-based index of the YAML document within the
+	// input which holds the resource
+	DocumentIndex int
+	// Line is the line of the input on which the resource starts, or 0
+	// if it isn't known
+	Line int
+	// ErrorPositions holds the position in the input of the value each of
+	// Errors is about, in the same order
+	ErrorPositions []Position
+	// ListPath leads to the resource from the List it was read from, and
+	// is empty for resources which aren't items of a List
+	ListPath ListPath
+	// ClusterScoped is whether the resource doesn't belong to a namespace,
+	// in which case ResourceNamespace is always empty
+	ClusterScoped bool
+	// Warnings describe problems which don't make the resource invalid
+	Warnings []string
+	// FieldErrors describes each of Errors in the same way as kubectl, in
+	// the same order
+	FieldErrors []FieldError
+	// SchemaLocation is the URL or path of the schema the resource was
+	// validated against, if it's known
+	SchemaLocation string
+
+	// skipped is whether the resource was deliberately not validated
+	// against a schema, as its kind is in KindsToSkip or it's a List
+	// without a schema of its own, rather than because none was found
+	skipped bool
 }
 
 // VersionKind returns a string representation of this result's apiVersion and kind
 func (v *ValidationResult) VersionKind() string {
-	return v.APIVersion + "/" + v.Kind
+	return versionKind(v.APIVersion, v.Kind)
+}
+
+// versionKind returns the key used to identify the schema for a given
+// apiVersion and kind
+func versionKind(apiVersion, kind string) string {
+	return apiVersion + "/" + kind
 }
 
 // QualifiedName returns a string of the [namespace.]name of the k8s resource
@@ -55,12 +93,7 @@ func determineSchemaURL(baseURL, kind, apiVersion string, config *Config) string
 	// the tool can toggle between then using the config.OpenShift boolean flag and here we
 	// use that to format the URL to match the required specification.
 
-	// Most of the directories which store the schemas are prefixed with a v so as to
-	// match the tagging in the Kubernetes repository, apart from master.
-	normalisedVersion := config.KubernetesVersion
-	if normalisedVersion != "master" {
-		normalisedVersion = "v" + normalisedVersion
-	}
+	normalisedVersion := normalisedKubernetesVersion(config)
 
 	strictSuffix := ""
 	if config.Strict {
@@ -83,6 +116,72 @@ func determineSchemaURL(baseURL, kind, apiVersion string, config *Config) string
 	return fmt.Sprintf("%s/%s-standalone%s/%s%s.json", baseURL, normalisedVersion, strictSuffix, strings.ToLower(kind), kindSuffix)
 }
 
+// schemaURLTemplateData holds the fields available to a schema location
+// written as a Go template, such as
+// https://example.com/{{ .Group }}/{{ .Kind | lower }}_{{ .ResourceAPIVersion }}.json
+type schemaURLTemplateData struct {
+	// KubernetesVersion is the version of Kubernetes as configured, such
+	// as master or 1.18.0
+	KubernetesVersion string
+	// NormalizedVersion is the version of Kubernetes as used in the
+	// default schema locations, such as master or v1.18.0
+	NormalizedVersion string
+	// Group is the API group of the resource, empty for the core group
+	Group string
+	// ResourceAPIVersion is the version within the API group, such as v1
+	ResourceAPIVersion string
+	// Kind is the kind of the resource as written in the manifest
+	Kind string
+	// StrictSuffix is -strict when validating strictly, or empty
+	StrictSuffix string
+}
+
+// isSchemaURLTemplate returns whether a schema location is a Go template
+// rather than a base URL
+func isSchemaURLTemplate(location string) bool {
+	return strings.Contains(location, "{{")
+}
+
+// parseSchemaURLTemplate parses a schema location written as a Go template
+func parseSchemaURLTemplate(location string) (*template.Template, error) {
+	return template.New("schema-location").Funcs(template.FuncMap{
+		"lower": strings.ToLower,
+	}).Parse(location)
+}
+
+// executeSchemaURLTemplate returns the schema URL for the given kind and
+// apiVersion from a parsed schema location template
+func executeSchemaURLTemplate(tmpl *template.Template, kind, apiVersion string, config *Config) (string, error) {
+	group, version := splitAPIVersion(apiVersion)
+	data := schemaURLTemplateData{
+		KubernetesVersion:  config.KubernetesVersion,
+		NormalizedVersion:  normalisedKubernetesVersion(config),
+		Group:              group,
+		ResourceAPIVersion: version,
+		Kind:               kind,
+	}
+	if config.Strict {
+		data.StrictSuffix = "-strict"
+	}
+	var buf strings.Builder
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// normalisedKubernetesVersion returns the version of Kubernetes as used in
+// the names of the directories which store the schemas
+func normalisedKubernetesVersion(config *Config) string {
+	// Most of the directories which store the schemas are prefixed with a v so as to
+	// match the tagging in the Kubernetes repository, apart from master.
+	normalisedVersion := config.KubernetesVersion
+	if normalisedVersion != "master" {
+		normalisedVersion = "v" + normalisedVersion
+	}
+	return normalisedVersion
+}
+
 func determineSchemaBaseURL(config *Config) string {
 	// Order of precendence:
 	// 1. If --openshift is passed, return the openshift schema location
@@ -111,14 +210,22 @@ func determineSchemaBaseURL(config *Config) string {
 // validateResource validates a single Kubernetes resource against
 // the relevant schema, detecting the type of resource automatically.
 // Returns the result and raw YAML body as map.
-func validateResource(data []byte, schemaCache map[string]*gojsonschema.Schema, config *Config) (ValidationResult, map[string]interface{}, error) {
+func validateResource(ctx context.Context, element document, schemaCache *SchemaCache, config *Config) (ValidationResult, map[string]interface{}, error) {
+	decoded, err := element.decode()
+	if err != nil {
+		result := ValidationResult{}
+		result.FileName = config.FileName
+		return result, decoded.body, newValidationError(ParseError, "Failed to decode YAML from %s: %s", result.FileName, err.Error())
+	}
+	return validateBody(ctx, decoded, schemaCache, config)
+}
+
+// validateBody validates a resource which has already been decoded
+func validateBody(ctx context.Context, decoded decodedResource, schemaCache *SchemaCache, config *Config) (ValidationResult, map[string]interface{}, error) {
+	body := decoded.body
 	result := ValidationResult{}
 	result.FileName = config.FileName
-	var body map[string]interface{}
-	err := yaml.Unmarshal(data, &body)
-	if err != nil {
-		return result, body, fmt.Errorf("Failed to decode YAML from %s: %s", result.FileName, err.Error())
-	} else if body == nil {
+	if body == nil {
 		return result, body, nil
 	}
 
@@ -138,47 +245,41 @@ func validateResource(data []byte, schemaCache map[string]*gojsonschema.Schema,
 
 	kind, err := getString(body, "kind")
 	if err != nil {
-		return result, body, fmt.Errorf("%s: %s", result.FileName, err.Error())
+		return result, body, newValidationError(ParseError, "%s: %s", result.FileName, err.Error())
 	}
 	result.Kind = kind
 
 	apiVersion, err := getString(body, "apiVersion")
 	if err != nil {
-		return result, body, fmt.Errorf("%s: %s", result.FileName, err.Error())
+		return result, body, newValidationError(ParseError, "%s: %s", result.FileName, err.Error())
 	}
 	result.APIVersion = apiVersion
 
 	if in(config.KindsToSkip, kind) {
+		result.skipped = true
 		return result, body, nil
 	}
 
 	if in(config.KindsToReject, kind) {
-		return result, body, fmt.Errorf("Prohibited resource kind '%s' in %s", kind, result.FileName)
+		return result, body, newValidationError(ProhibitedKind, "Prohibited resource kind '%s' in %s", kind, result.FileName)
 	}
 
-	schemaErrors, err := validateAgainstSchema(body, &result, schemaCache, config)
+	schemaErrors, err := validateAgainstSchema(ctx, decoded, &result, schemaCache, config)
 	if err != nil {
-		return result, body, fmt.Errorf("%s: %s", result.FileName, err.Error())
+		return result, body, newValidationError(MissingSchema, "%s: %s", result.FileName, err.Error())
 	}
 	result.Errors = schemaErrors
 	return result, body, nil
 }
 
-func validateAgainstSchema(body interface{}, resource *ValidationResult, schemaCache map[string]*gojsonschema.Schema, config *Config) ([]gojsonschema.ResultError, error) {
+func validateAgainstSchema(ctx context.Context, decoded decodedResource, resource *ValidationResult, schemaCache *SchemaCache, config *Config) ([]gojsonschema.ResultError, error) {
 
-	schema, err := downloadSchema(resource, schemaCache, config)
+	schema, err := downloadSchema(ctx, resource, schemaCache, config)
 	if err != nil || schema == nil {
 		return handleMissingSchema(err, config)
 	}
 
-	// Without forcing these types the schema fails to load
-	// Need to Work out proper handling for these types
-	gojsonschema.FormatCheckers.Add("int64", ValidFormat{})
-	gojsonschema.FormatCheckers.Add("byte", ValidFormat{})
-	gojsonschema.FormatCheckers.Add("int32", ValidFormat{})
-	gojsonschema.FormatCheckers.Add("int-or-string", ValidFormat{})
-
-	documentLoader := gojsonschema.NewGoLoader(body)
+	documentLoader := gojsonschema.NewGoLoader(decoded.body)
 	results, err := schema.Validate(documentLoader)
 	if err != nil {
 		// This error can only happen if the Object to validate is poorly formed. There's no hope of saving this one
@@ -186,52 +287,164 @@ func validateAgainstSchema(body interface{}, resource *ValidationResult, schemaC
 		return []gojsonschema.ResultError{}, wrappedErr
 	}
 	resource.ValidatedAgainstSchema = true
-	if !results.Valid() {
-		return results.Errors(), nil
+	resource.SchemaLocation = schemaCache.location(resource.APIVersion, resource.Kind)
+	errors := append(results.Errors(), checkDecimalIntegers(schema, decoded, results.Errors())...)
+	if len(errors) > 0 {
+		return filterFormatErrors(errors, config), nil
 	}
 
 	return []gojsonschema.ResultError{}, nil
 }
 
 // returned schema may be nil scehma is missing and missing schemas are allowed
-func downloadSchema(resource *ValidationResult, schemaCache map[string]*gojsonschema.Schema, config *Config) (*gojsonschema.Schema, error) {
-	if schema, ok := schemaCache[resource.VersionKind()]; ok {
-		// If the schema was previously cached, there's no work to be done
-		return schema, nil
+func downloadSchema(ctx context.Context, resource *ValidationResult, schemaCache *SchemaCache, config *Config) (*gojsonschema.Schema, error) {
+	// If the schema was previously cached, or is being found by another
+	// goroutine, there's no more work to be done. Otherwise ask the
+	// provider for one, caching its lack of existence if none is found.
+	return schemaCache.getOrLoad(resource.APIVersion, resource.Kind, func() (*gojsonschema.Schema, error) {
+		provider := config.SchemaProvider
+		if provider == nil {
+			provider = NewDefaultSchemaProvider(config)
+		}
+		schema, document, err := findSchema(ctx, provider, resource.APIVersion, resource.Kind)
+		if err == nil && schema == nil {
+			err = fmt.Errorf("No schema for %s", resource.VersionKind())
+		}
+		if err != nil && ctx.Err() != nil {
+			// The search may have failed because the context is done,
+			// in which case there may still be a schema
+			err = fmt.Errorf("%w: %s", ctx.Err(), err)
+		}
+		if locator, ok := provider.(schemaLocator); ok && err == nil {
+			schemaCache.putLocation(resource.VersionKind(), locator.schemaLocation(resource.APIVersion, resource.Kind))
+		}
+		if document != nil && err == nil {
+			// Keep the document for suggestions, rather than reading it again
+			schemaCache.putDocument(resource.VersionKind(), document)
+		}
+		return schema, err
+	})
+}
+
+// compileSchema compiles a schema which kubeval built itself, rather than
+// downloaded, prohibiting additional properties if running in strict mode
+func compileSchema(key string, raw map[string]interface{}, config *Config) (*gojsonschema.Schema, error) {
+	if config.Strict {
+		raw = strictJSONSchema(raw)
+	}
+	schema, err := gojsonschema.NewSchema(gojsonschema.NewGoLoader(raw))
+	if err != nil {
+		return nil, fmt.Errorf("Failed initializing schema for %s: %s", key, err)
 	}
+	return schema, nil
+}
 
-	// We haven't cached this schema yet; look for one that works
-	primarySchemaBaseURL := determineSchemaBaseURL(config)
-	primarySchemaRef := determineSchemaURL(primarySchemaBaseURL, resource.Kind, resource.APIVersion, config)
-	schemaRefs := []string{primarySchemaRef}
+// newSchemaLoader returns a loader for the schema at schemaRef, going
+// through the on-disk cache for schemas downloaded over HTTP(S)
+func newSchemaLoader(ctx context.Context, schemaRef string, config *Config) gojsonschema.JSONLoader {
+	return quantityLoader{cachedLoaderFactory{ctx, newDiskCache(config)}.New(schemaRef)}
+}
 
-	for _, additionalSchemaURLs := range config.AdditionalSchemaLocations {
-		additionalSchemaRef := determineSchemaURL(additionalSchemaURLs, resource.Kind, resource.APIVersion, config)
-		schemaRefs = append(schemaRefs, additionalSchemaRef)
+// compileLoadedSchema compiles the schema read by loader, and returns the
+// document it was compiled from as well. The document is read only once,
+// while relative $refs are still resolved against the loader's reference.
+func compileLoadedSchema(loader gojsonschema.JSONLoader) (*gojsonschema.Schema, interface{}, error) {
+	document, err := loader.LoadJSON()
+	if err != nil {
+		return nil, nil, err
+	}
+	ref, err := loader.JsonReference()
+	if err != nil {
+		return nil, nil, err
+	}
+	schemaLoader := gojsonschema.NewSchemaLoader()
+	root := gojsonschema.JSONLoader(gojsonschema.NewGoLoader(document))
+	if ref.String() != "" {
+		// Compiling from the reference finds the copy of the document added
+		// under it, rather than reading the document again. The copy is
+		// added as its $refs are rewritten in place.
+		if err := schemaLoader.AddSchema(ref.String(), gojsonschema.NewGoLoader(document)); err != nil {
+			return nil, nil, err
+		}
+		root = loader
+	}
+	schema, err := schemaLoader.Compile(root)
+	if err != nil {
+		return nil, nil, err
 	}
+	return schema, document, nil
+}
 
-	var errors *multierror.Error
+// cachedLoader loads the document at a reference, reading remote documents
+// through the on-disk cache. The reference is kept so that relative $refs,
+// such as those to _definitions.json, are resolved against it, and the
+// documents they refer to are read through the cache in the same way.
+// Downloads stop once ctx is done.
+type cachedLoader struct {
+	gojsonschema.JSONLoader
+	ctx   context.Context
+	cache *diskCache
+}
 
-	for _, schemaRef := range schemaRefs {
-		schemaLoader := gojsonschema.NewReferenceLoader(schemaRef)
-		schema, err := gojsonschema.NewSchema(schemaLoader)
-		if err == nil {
-			// success! cache this and stop looking
-			schemaCache[resource.VersionKind()] = schema
-			return schema, nil
-		}
-		// We couldn't find a schema for this URL, so take a note, then try the next URL
-		wrappedErr := fmt.Errorf("Failed initializing schema %s: %s", schemaRef, err)
-		errors = multierror.Append(errors, wrappedErr)
+func (l cachedLoader) LoadJSON() (interface{}, error) {
+	source := l.JsonSource().(string)
+	if !isRemote(source) {
+		return l.JSONLoader.LoadJSON()
+	}
+	body, err := readLocation(l.ctx, strings.SplitN(source, "#", 2)[0], l.cache)
+	if err != nil {
+		return nil, err
 	}
+	var document interface{}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	if err := decoder.Decode(&document); err != nil {
+		return nil, err
+	}
+	return document, nil
+}
 
-	if errors != nil {
-		errors.ErrorFormat = singleLineErrorFormat
+func (l cachedLoader) LoaderFactory() gojsonschema.JSONLoaderFactory {
+	return cachedLoaderFactory{l.ctx, l.cache}
+}
+
+type cachedLoaderFactory struct {
+	ctx   context.Context
+	cache *diskCache
+}
+
+func (f cachedLoaderFactory) New(source string) gojsonschema.JSONLoader {
+	return cachedLoader{gojsonschema.NewReferenceLoader(source), f.ctx, f.cache}
+}
+
+// quantityLoader gives the resource quantities in schemas published for
+// kubeval the quantity format, which the schemas built from OpenAPI
+// documents already have. The published schemas only describe a quantity
+// as either a string or a number. Documents loaded through a $ref are
+// rewritten in the same way.
+type quantityLoader struct {
+	gojsonschema.JSONLoader
+}
+
+func (l quantityLoader) LoadJSON() (interface{}, error) {
+	document, err := l.JSONLoader.LoadJSON()
+	if err != nil {
+		return nil, err
 	}
+	markQuantities(document)
+	return document, nil
+}
+
+func (l quantityLoader) LoaderFactory() gojsonschema.JSONLoaderFactory {
+	return quantityLoaderFactory{l.JSONLoader.LoaderFactory()}
+}
+
+type quantityLoaderFactory struct {
+	gojsonschema.JSONLoaderFactory
+}
 
-	// We couldn't find a schema for this resource. Cache its lack of existence
-	schemaCache[resource.VersionKind()] = nil
-	return nil, errors.ErrorOrNil()
+func (f quantityLoaderFactory) New(source string) gojsonschema.JSONLoader {
+	return quantityLoader{f.JSONLoaderFactory.New(source)}
 }
 
 func handleMissingSchema(err error, config *Config) ([]gojsonschema.ResultError, error) {
@@ -242,7 +455,8 @@ func handleMissingSchema(err error, config *Config) ([]gojsonschema.ResultError,
 }
 
 // NewSchemaCache returns a new schema cache to be used with
-// ValidateWithCache
+// ValidateWithCache. Use NewSharedSchemaCache instead to share a cache
+// between goroutines.
 func NewSchemaCache() map[string]*gojsonschema.Schema {
 	return make(map[string]*gojsonschema.Schema, 0)
 }
@@ -250,129 +464,374 @@ func NewSchemaCache() map[string]*gojsonschema.Schema {
 // Validate a Kubernetes YAML file, parsing out individual resources
 // and validating them all according to the  relevant schemas
 func Validate(input []byte, conf ...*Config) ([]ValidationResult, error) {
-	schemaCache := NewSchemaCache()
-	return ValidateWithCache(input, schemaCache, conf...)
+	return ValidateWithSchemaCache(input, NewSharedSchemaCache(), conf...)
 }
 
 // ValidateWithCache validates a Kubernetes YAML file, parsing out individual resources
 // and validating them all according to the relevant schemas
 // Allows passing a kubeval.NewSchemaCache() to cache schemas in-memory
-// between validations
+// between validations. The map is not safe to share between goroutines.
 func ValidateWithCache(input []byte, schemaCache map[string]*gojsonschema.Schema, conf ...*Config) ([]ValidationResult, error) {
+	return ValidateWithSchemaCache(input, newSchemaCacheFromMap(schemaCache), conf...)
+}
+
+// ValidateWithSchemaCache validates a Kubernetes YAML file in the same way
+// as ValidateWithCache, caching schemas in a SchemaCache which may be
+// shared between goroutines validating at the same time. Each goroutine
+// needs its own Config, which is modified while validating.
+func ValidateWithSchemaCache(input []byte, schemaCache *SchemaCache, conf ...*Config) ([]ValidationResult, error) {
 	config := NewDefaultConfig()
 	if len(conf) == 1 {
 		config = conf[0]
 	}
+	return validateInput(ParseInput(input), schemaCache, config, config.Concurrency > 1)
+}
+
+// Input is a Kubernetes YAML file which has been split into its documents,
+// with any Lists expanded into their items, and parsed. Its schemas can be
+// prefetched and it can be validated without parsing it again.
+type Input struct {
+	empty     bool
+	documents []document
+}
+
+// ParseInput splits a Kubernetes YAML file into its documents and parses
+// each of them, ready to be validated with ValidateInput
+func ParseInput(input []byte) *Input {
+	parsed := &Input{empty: len(input) == 0}
+	for _, element := range decodeDocuments(input) {
+		parsed.documents = append(parsed.documents, expandList(element)...)
+	}
+	return parsed
+}
 
+// ValidateInput validates a parsed Kubernetes YAML file in the same way as
+// ValidateWithSchemaCache, but without prefetching its schemas
+func ValidateInput(input *Input, schemaCache *SchemaCache, conf ...*Config) ([]ValidationResult, error) {
+	config := NewDefaultConfig()
+	if len(conf) == 1 {
+		config = conf[0]
+	}
+	return validateInput(input, schemaCache, config, false)
+}
+
+// validateInput validates a parsed input, first prefetching its schemas if
+// prefetch is set
+func validateInput(input *Input, schemaCache *SchemaCache, config *Config, prefetch bool) ([]ValidationResult, error) {
 	results := make([]ValidationResult, 0)
 
 	if len(config.DefaultNamespace) == 0 {
 		return results, fmt.Errorf("Default namespace ('-n/--default-namespace' flag) must not be empty")
 	}
 
-	if len(input) == 0 {
+	if input.empty {
 		result := ValidationResult{}
 		result.FileName = config.FileName
 		results = append(results, result)
 		return results, nil
 	}
 
-	splitBits := bytes.Split(input, []byte(detectLineBreak(input)+"---"+detectLineBreak(input)))
-	bits := make([][]byte, len(splitBits))
-	j := 0
+	ctx := context.Background()
+	validation, finish := beginValidation(ctx, schemaCache, config)
+	defer finish()
+
+	if prefetch {
+		prefetchSchemas(ctx, input.documents, schemaCache, config)
+	}
+
+	for _, element := range input.documents {
+		result, stop := validation.validate(element)
+		if stop {
+			return results, validation.err()
+		}
+		results = append(results, result)
+	}
+	return results, validation.err()
+}
+
+// ValidateStream validates the Kubernetes YAML documents read from r one
+// at a time, passing the result for each resource to handle as soon as it
+// has been validated, so that only one document is held in memory at
+// once. It stops early if ctx is cancelled, if handle returns an error, or
+// at the first error when ExitOnError is set, and otherwise returns the
+// same errors as ValidateWithSchemaCache. Schemas aren't prefetched.
+func ValidateStream(ctx context.Context, r io.Reader, schemaCache *SchemaCache, handle func(ValidationResult) error, conf ...*Config) error {
+	config := NewDefaultConfig()
+	if len(conf) == 1 {
+		config = conf[0]
+	}
 
-	// split any list into its elements and add them to "bits"
-	for _, element := range splitBits {
+	if len(config.DefaultNamespace) == 0 {
+		return fmt.Errorf("Default namespace ('-n/--default-namespace' flag) must not be empty")
+	}
 
-		list := struct {
-			Version string
-			Kind    string
-			Items   []interface{}
-		}{}
+	validation, finish := beginValidation(ctx, schemaCache, config)
+	defer finish()
 
-		unmarshalErr := yaml.Unmarshal(element, &list)
-		isYamlList := unmarshalErr == nil && list.Items != nil
+	decoder := newDocumentDecoder(r)
+	empty := true
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		element, err := decoder.next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return &ValidationError{Category: ParseError, FileName: config.FileName, Err: fmt.Errorf("Failed to read %s: %s", config.FileName, err.Error())}
+		}
+		empty = false
 
-		if isYamlList {
-			listBits := make([][]byte, len(list.Items))
-			for i, item := range list.Items {
-				b, _ := yaml.Marshal(item)
-				listBits[i] = b
+		for _, bit := range expandList(element) {
+			result, stop := validation.validate(bit)
+			if stop {
+				return validation.err()
+			}
+			if err := handle(result); err != nil {
+				return err
 			}
-			bits = append(bits, listBits...)
-			j += len(list.Items)
-		} else {
-			bits[j] = element
-			j++
 		}
 	}
 
-	var errors *multierror.Error
+	// An empty input is reported as an empty document, as with Validate
+	if empty {
+		if err := handle(ValidationResult{FileName: config.FileName}); err != nil {
+			return err
+		}
+	}
+	return validation.err()
+}
+
+// inputValidation holds the state shared by the documents of a single
+// input while they are validated in turn
+type inputValidation struct {
+	ctx         context.Context
+	schemaCache *SchemaCache
+	config      *Config
 
 	// special case regexp for helm
-	helmSourcePattern := regexp.MustCompile(`^(?:---` + detectLineBreak(input) + `)?# Source: (.*)`)
+	helmSourcePattern *regexp.Regexp
+	duplicates        *DuplicateTracker
+	errors            *multierror.Error
+}
 
+// beginValidation prepares config for validating an input, returning the
+// state of the validation and a function which restores config afterwards
+func beginValidation(ctx context.Context, schemaCache *SchemaCache, config *Config) (*inputValidation, func()) {
 	// Save the fileName we were provided; if we detect a new fileName
 	// we'll use that, but we'll need to revert to the default afterward
 	originalFileName := config.FileName
-	defer func() {
-		// revert the filename back to the original
+
+	// Build the default schema provider once for the whole input, so that
+	// CRD and OpenAPI locations are read at most once
+	originalSchemaProvider := config.SchemaProvider
+	if config.SchemaProvider == nil {
+		config.SchemaProvider = NewDefaultSchemaProvider(config)
+	}
+
+	validation := &inputValidation{
+		ctx:               ctx,
+		schemaCache:       schemaCache,
+		config:            config,
+		helmSourcePattern: regexp.MustCompile(`^(?:---\r?\n)?# Source: ([^\r\n]*)`),
+		duplicates:        config.DuplicateTracker,
+	}
+	if validation.duplicates == nil {
+		validation.duplicates = NewDuplicateTracker()
+	}
+	return validation, func() {
 		config.FileName = originalFileName
-	}()
+		config.SchemaProvider = originalSchemaProvider
+	}
+}
 
-	seenResourcesSet := make(map[[4]string]bool) // set of [API version, kind, namespace, name]
+// validate validates a single document, or item of a List, returning its
+// result and whether validation should stop because of ExitOnError
+func (v *inputValidation) validate(element document) (ValidationResult, bool) {
+	config := v.config
+	if len(element.data) == 0 && element.node == nil {
+		result := ValidationResult{}
+		result.FileName = config.FileName
+		result.DocumentIndex = element.index
+		return result, false
+	}
 
-	for _, element := range bits {
-		if len(element) > 0 {
-			if found := helmSourcePattern.FindStringSubmatch(string(element)); found != nil {
-				config.FileName = found[1]
-			}
+	if found := v.helmSourcePattern.FindStringSubmatch(string(element.data)); found != nil {
+		config.FileName = found[1]
+	}
 
-			result, body, err := validateResource(element, schemaCache, config)
-			if err != nil {
-				errors = multierror.Append(errors, err)
-				if config.ExitOnError {
-					return results, errors
-				}
+	var result ValidationResult
+	var body map[string]interface{}
+	var err error
+	if element.envelope {
+		result, body, err = validateEnvelope(v.ctx, element, v.schemaCache, config)
+	} else {
+		result, body, err = validateResource(v.ctx, element, v.schemaCache, config)
+	}
+	result.ListPath = element.listPath
+	setPositions(&result, element)
+	if err != nil {
+		validationErr := asValidationError(err)
+		locateValidationError(validationErr, result.FileName, result.Line, element.line)
+		if len(element.listPath) > 0 {
+			validationErr.Err = fmt.Errorf("%s (in %s)", validationErr.Err.Error(), describeListPath(element.listPath))
+		}
+		v.errors = multierror.Append(v.errors, validationErr)
+	}
+
+	// Custom resources later in the input are validated against the
+	// schemas declared by any CustomResourceDefinition, even one which
+	// couldn't itself be validated, such as when there is no schema for
+	// CustomResourceDefinitions
+	if isCustomResourceDefinition(body) {
+		if err := cacheCRDSchemas(body, v.schemaCache, config); err != nil {
+			crdErr := newValidationError(ParseError, "%s: %s", result.FileName, err.Error())
+			locateValidationError(crdErr, result.FileName, result.Line, element.line)
+			v.errors = multierror.Append(v.errors, crdErr)
+		}
+	}
+	if err != nil {
+		return result, config.ExitOnError
+	}
+
+	if config.CheckYAML {
+		for _, finding := range checkYAML(element.node, element.line, element.envelope) {
+			result.Errors = append(result.Errors, finding.err)
+			result.ErrorPositions = append(result.ErrorPositions, finding.position)
+		}
+	}
+	result.FieldErrors = newFieldErrors(result.Errors)
+	if hasErrorType(result.Errors, "additional_property_not_allowed") {
+		suggestFields(&result, findSchemaDocument(v.ctx, result.APIVersion, result.Kind, v.schemaCache, config.SchemaProvider))
+	}
+
+	// The API server ignores the namespace of cluster-scoped resources
+	if result.Kind != "" {
+		result.ClusterScoped = isClusterScoped(result.APIVersion, result.Kind, v.schemaCache, config.SchemaProvider)
+		if result.ClusterScoped && result.ResourceNamespace != "" {
+			result.Warnings = append(result.Warnings, fmt.Sprintf("Cluster-scoped %s sets metadata.namespace '%s', which is ignored", result.Kind, result.ResourceNamespace))
+			result.ResourceNamespace = ""
+		}
+	}
+
+	if !in(config.KindsToSkip, result.Kind) {
+
+		metadata, _ := getObject(body, "metadata")
+		if metadata != nil {
+			namespace, _ := getString(metadata, "namespace")
+			name, _ := getString(metadata, "name")
+
+			var resolvedNamespace string
+			if result.ClusterScoped {
+				resolvedNamespace = ""
+			} else if len(namespace) > 0 {
+				resolvedNamespace = namespace
 			} else {
-				if !in(config.KindsToSkip, result.Kind) {
-
-					metadata, _ := getObject(body, "metadata")
-					if metadata != nil {
-						namespace, _ := getString(metadata, "namespace")
-						name, _ := getString(metadata, "name")
-
-						var resolvedNamespace string
-						if len(namespace) > 0 {
-							resolvedNamespace = namespace
-						} else {
-							resolvedNamespace = config.DefaultNamespace
-						}
-
-						// If resource has `metadata:name` attribute
-						if len(resolvedNamespace) > 0 && len(name) > 0 {
-							key := [4]string{result.APIVersion, result.Kind, resolvedNamespace, name}
-							if _, hasDuplicate := seenResourcesSet[key]; hasDuplicate {
-								errors = multierror.Append(errors, fmt.Errorf("%s: Duplicate '%s' resource '%s' in namespace '%s'", result.FileName, result.Kind, name, namespace))
-							}
-
-							seenResourcesSet[key] = true
-						}
+				resolvedNamespace = config.DefaultNamespace
+			}
+
+			// If resource has `metadata:name` attribute
+			if (result.ClusterScoped || len(resolvedNamespace) > 0) && len(name) > 0 {
+				location := ResourceLocation{FileName: result.FileName, Line: result.Line, ListPath: result.ListPath}
+				if first, hasDuplicate := v.duplicates.Track(result.APIVersion, result.Kind, resolvedNamespace, name, location); hasDuplicate {
+					var duplicateErr *ValidationError
+					if result.ClusterScoped {
+						duplicateErr = newValidationError(DuplicateResource, "%s: Duplicate cluster-scoped '%s' resource '%s' at %s, first defined at %s", result.FileName, result.Kind, name, location, first)
+					} else {
+						duplicateErr = newValidationError(DuplicateResource, "%s: Duplicate '%s' resource '%s' in namespace '%s' at %s, first defined at %s", result.FileName, result.Kind, name, resolvedNamespace, location, first)
 					}
+					locateValidationError(duplicateErr, result.FileName, result.Line, element.line)
+					v.errors = multierror.Append(v.errors, duplicateErr)
 				}
 			}
-			results = append(results, result)
-		} else {
-			result := ValidationResult{}
-			result.FileName = config.FileName
-			results = append(results, result)
 		}
 	}
+	return result, false
+}
 
-	if errors != nil {
-		errors.ErrorFormat = singleLineErrorFormat
+// err returns the errors found while validating the input so far
+func (v *inputValidation) err() error {
+	if v.errors != nil {
+		v.errors.ErrorFormat = singleLineErrorFormat
+	}
+	return v.errors.ErrorOrNil()
+}
+
+// PrefetchSchemas finds the schemas for every type of resource in the
+// given inputs, config.Concurrency at a time, and caches them in
+// schemaCache without validating anything. Validating the inputs
+// afterwards, or while they are prefetched, then doesn't wait on each
+// download in turn. Kinds declared by a CustomResourceDefinition in the
+// same inputs aren't looked for, as their schemas are cached when the
+// definition is validated. No more downloads are started once ctx is done.
+func PrefetchSchemas(ctx context.Context, inputs []*Input, schemaCache *SchemaCache, conf ...*Config) {
+	config := NewDefaultConfig()
+	if len(conf) == 1 {
+		config = conf[0]
+	}
+	prefetchConfig := *config
+	if prefetchConfig.SchemaProvider == nil {
+		prefetchConfig.SchemaProvider = NewDefaultSchemaProvider(config)
+	}
+
+	var documents []document
+	for _, input := range inputs {
+		documents = append(documents, input.documents...)
+	}
+	prefetchSchemas(ctx, documents, schemaCache, &prefetchConfig)
+}
+
+// prefetchSchemas finds the schemas for every type of resource in the
+// given documents, config.Concurrency at a time, so that validating them
+// one by one afterwards doesn't wait on each schema in turn. Errors are
+// cached along with the missing schemas, and reported when validating.
+// Custom resources declared by a CustomResourceDefinition in the same
+// documents are left alone, as their schemas are cached while validating.
+func prefetchSchemas(ctx context.Context, documents []document, schemaCache *SchemaCache, config *Config) {
+	seen := make(map[string]bool)
+	declared := make(map[string]bool)
+	var resources []*ValidationResult
+	for _, document := range documents {
+		document.parse()
+		kind, _ := stringAt(document.node, "kind")
+		apiVersion, _ := stringAt(document.node, "apiVersion")
+		if kind == "" || apiVersion == "" || in(config.KindsToSkip, kind) || in(config.KindsToReject, kind) {
+			continue
+		}
+		if kind == "CustomResourceDefinition" {
+			decoded, _ := document.decode()
+			if isCustomResourceDefinition(decoded.body) {
+				schemas, _ := crdSchemas(decoded.body)
+				for key := range schemas {
+					declared[key] = true
+				}
+			}
+		}
+		resource := &ValidationResult{APIVersion: apiVersion, Kind: kind}
+		if !seen[resource.VersionKind()] {
+			seen[resource.VersionKind()] = true
+			resources = append(resources, resource)
+		}
+	}
+
+	var wg sync.WaitGroup
+	semaphore := make(chan struct{}, config.Concurrency)
+	for _, resource := range resources {
+		if ctx.Err() != nil {
+			break
+		}
+		if declared[resource.VersionKind()] {
+			continue
+		}
+		wg.Add(1)
+		semaphore <- struct{}{}
+		go func(resource *ValidationResult) {
+			defer wg.Done()
+			defer func() { <-semaphore }()
+			_, _ = downloadSchema(ctx, resource, schemaCache, config)
+		}(resource)
 	}
-	return results, errors.ErrorOrNil()
+	wg.Wait()
 }
 
 func singleLineErrorFormat(es []error) string {
@@ -410,6 +410,109 @@ func TestSkipCrdSchemaMiss(t *testing.T) {
 	}
 }
 
+func TestValidateAgainstCrdInInput(t *testing.T) {
+	var tests = []struct {
+		Fixture string
+		Valid   bool
+	}{
+		{
+			Fixture: "crd_with_resources.yaml",
+			Valid:   true,
+		},
+		{
+			Fixture: "crd_with_invalid_resources.yaml",
+			Valid:   false,
+		},
+	}
+	for _, test := range tests {
+		config := NewDefaultConfig()
+		config.FileName = test.Fixture
+		// the CustomResourceDefinition itself would need a schema download
+		config.KindsToSkip = []string{"CustomResourceDefinition"}
+		filePath, _ := filepath.Abs("../fixtures/" + test.Fixture)
+		fileContents, _ := ioutil.ReadFile(filePath)
+		results, err := Validate(fileContents, config)
+		if err != nil {
+			t.Errorf("Unexpected error for %s: %s", test.Fixture, err.Error())
+			continue
+		}
+		if !results[1].ValidatedAgainstSchema {
+			t.Errorf("Custom resource in %s should be validated against the schema from its CRD", test.Fixture)
+		}
+		if valid := len(results[1].Errors) == 0; valid != test.Valid {
+			t.Errorf("Custom resource in %s should have valid=%t, got errors: %v", test.Fixture, test.Valid, results[1].Errors)
+		}
+	}
+}
+
+func TestValidateAgainstCrdWithoutSchema(t *testing.T) {
+	// There is no schema for CustomResourceDefinitions in fixtures/schemas,
+	// but its custom resources can still be validated
+	schemaLocation, _ := filepath.Abs("../fixtures/schemas")
+	config := NewDefaultConfig()
+	config.FileName = "crd_with_invalid_resources.yaml"
+	config.SchemaLocation = "file://" + schemaLocation
+	fileContents, _ := ioutil.ReadFile("../fixtures/crd_with_invalid_resources.yaml")
+	results, err := Validate(fileContents, config)
+
+	var validationErrs []*ValidationError
+	if merr, ok := err.(*multierror.Error); ok {
+		for _, e := range merr.Errors {
+			validationErrs = append(validationErrs, asValidationError(e))
+		}
+	}
+	if len(validationErrs) != 1 || validationErrs[0].Category != MissingSchema {
+		t.Errorf("Expected only the missing schema for the CustomResourceDefinition, got: %v", err)
+	}
+	if len(results) != 2 || !results[1].ValidatedAgainstSchema {
+		t.Fatalf("Custom resource should be validated against the schema from its CRD, got %+v", results)
+	}
+	if len(results[1].Errors) == 0 {
+		t.Errorf("Expected errors for the invalid custom resource")
+	}
+}
+
+func TestValidateAgainstCrdLocation(t *testing.T) {
+	var tests = []struct {
+		Fixture string
+		Strict  bool
+		Valid   bool
+	}{
+		{
+			Fixture: "test_crd.yaml",
+			Valid:   true,
+		},
+		{
+			Fixture: "test_crd_extra_property.yaml",
+			Valid:   true,
+		},
+		{
+			Fixture: "test_crd_extra_property.yaml",
+			Strict:  true,
+			Valid:   false,
+		},
+	}
+	for _, test := range tests {
+		config := NewDefaultConfig()
+		config.FileName = test.Fixture
+		config.Strict = test.Strict
+		config.CRDLocations = []string{"../fixtures/crds"}
+		filePath, _ := filepath.Abs("../fixtures/" + test.Fixture)
+		fileContents, _ := ioutil.ReadFile(filePath)
+		results, err := Validate(fileContents, config)
+		if err != nil {
+			t.Errorf("Unexpected error for %s: %s", test.Fixture, err.Error())
+			continue
+		}
+		if !results[0].ValidatedAgainstSchema {
+			t.Errorf("Custom resource in %s should be validated against the schema from --crd-location", test.Fixture)
+		}
+		if valid := len(results[0].Errors) == 0; valid != test.Valid {
+			t.Errorf("Custom resource in %s with strict=%t should have valid=%t, got errors: %v", test.Fixture, test.Strict, test.Valid, results[0].Errors)
+		}
+	}
+}
+
 func TestAdditionalSchemas(t *testing.T) {
 	// This test uses a hack - first tell kubeval to use a bogus URL as its
 	// primary search location, then give the DefaultSchemaLocation as an
@@ -448,6 +551,15 @@ func TestFlagAdding(t *testing.T) {
 		"schema-location",
 		"additional-schema-locations",
 		"kubernetes-version",
+		"crd-location",
+		"cache-dir",
+		"cache-ttl",
+		"no-cache",
+		"openapi-spec",
+		"openapi-v3-location",
+		"concurrency",
+		"check-yaml",
+		"strict-formats",
 	}
 
 	for _, expected := range expectedFlags {
@@ -0,0 +1,142 @@
+package kubeval
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	yamlv3 "gopkg.in/yaml.v3"
+)
+
+// ListItem identifies an item of a List
+type ListItem struct {
+	// Kind and Name are those of the List
+	Kind  string `json:"kind"`
+	Name  string `json:"name,omitempty"`
+	Index int    `json:"index"`
+}
+
+// ListPath leads from the outermost List a resource was read from, through
+// any Lists nested within it, to the resource
+type ListPath []ListItem
+
+// String returns the path as fields of the outermost List, such as
+// items[1].items[0]
+func (p ListPath) String() string {
+	fields := make([]string, len(p))
+	for i, item := range p {
+		fields[i] = fmt.Sprintf("items[%d]", item.Index)
+	}
+	return strings.Join(fields, ".")
+}
+
+// describeListPath describes where a resource is within the outermost List
+// it was read from, for use in messages
+func describeListPath(p ListPath) string {
+	if len(p) == 0 {
+		return ""
+	}
+	if p[0].Name == "" {
+		return fmt.Sprintf("%s of %s", p, p[0].Kind)
+	}
+	return fmt.Sprintf("%s of %s (%s)", p, p[0].Kind, p[0].Name)
+}
+
+// listItems returns the nodes of the items of a List, such as a v1/List
+// or a ConfigMapList, given the root node of a resource, and whether the
+// resource is a List
+func listItems(root *yamlv3.Node) ([]*yamlv3.Node, bool) {
+	kind, _ := stringAt(root, "kind")
+	if !strings.HasSuffix(kind, "List") {
+		return nil, false
+	}
+	_, items := lookupNode(root, "items")
+	items = resolveAlias(items)
+	switch {
+	case items == nil:
+		return nil, false
+	case items.Kind == yamlv3.SequenceNode:
+		return items.Content, true
+	case items.Kind == yamlv3.ScalarNode && items.ShortTag() == "!!null":
+		return nil, true
+	}
+	return nil, false
+}
+
+// expandList returns the documents to validate for a document: the
+// document itself, or for a List, the List followed by each of its items,
+// expanding any nested Lists in turn. The items are found in the List's
+// node tree, so the document is only parsed once.
+func expandList(element document) []document {
+	element.parse()
+	items, ok := listItems(element.node)
+	if !ok {
+		return []document{element}
+	}
+
+	kind, _ := stringAt(element.node, "kind")
+	name, _ := stringAt(element.node, "metadata", "name")
+
+	envelope := element
+	envelope.envelope = true
+	bits := []document{envelope}
+	for i, item := range items {
+		listPath := make(ListPath, len(element.listPath), len(element.listPath)+1)
+		copy(listPath, element.listPath)
+		listPath = append(listPath, ListItem{Kind: kind, Name: name, Index: i})
+		bits = append(bits, expandList(document{
+			offset:   element.offset,
+			line:     element.line,
+			index:    element.index,
+			node:     item,
+			parsed:   true,
+			listPath: listPath,
+		})...)
+	}
+	return bits
+}
+
+// validateEnvelope validates a List without its items, which are validated
+// as resources of their own. Lists such as v1/List have no schema, so the
+// List is only validated against one if it's found. The items aren't
+// decoded, so that a large List isn't held in memory twice.
+func validateEnvelope(ctx context.Context, element document, schemaCache *SchemaCache, config *Config) (ValidationResult, map[string]interface{}, error) {
+	element.parse()
+	if element.node != nil {
+		element.node = withoutItems(element.node)
+	}
+	envelope, err := element.decode()
+	if err != nil {
+		result := ValidationResult{}
+		result.FileName = config.FileName
+		return result, envelope.body, newValidationError(ParseError, "Failed to decode YAML from %s: %s", result.FileName, err.Error())
+	}
+
+	envelopeConfig := *config
+	envelopeConfig.IgnoreMissingSchemas = true
+	result, body, err := validateBody(ctx, envelope, schemaCache, &envelopeConfig)
+	result.skipped = result.skipped || !result.ValidatedAgainstSchema
+	return result, body, err
+}
+
+// withoutItems returns a copy of the root node of a List with an empty
+// sequence in place of its items
+func withoutItems(root *yamlv3.Node) *yamlv3.Node {
+	root = resolveAlias(root)
+	envelope := *root
+	envelope.Content = make([]*yamlv3.Node, 0, len(root.Content)+2)
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		key, value := root.Content[i], root.Content[i+1]
+		if key.Tag != "!!merge" && key.Value == "items" {
+			continue
+		}
+		envelope.Content = append(envelope.Content, key, value)
+	}
+	// Keys written in a mapping take the place of any merged in with <<,
+	// so this also replaces items merged into the List
+	envelope.Content = append(envelope.Content,
+		&yamlv3.Node{Kind: yamlv3.ScalarNode, Tag: "!!str", Value: "items"},
+		&yamlv3.Node{Kind: yamlv3.SequenceNode, Tag: "!!seq"},
+	)
+	return &envelope
+}
@@ -0,0 +1,105 @@
+package kubeval
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/xeipuuv/gojsonschema"
+)
+
+func TestValidateNestedLists(t *testing.T) {
+	filePath, _ := filepath.Abs("../fixtures/list_nested.yaml")
+	fileContents, _ := ioutil.ReadFile(filePath)
+	schemaLocation, _ := filepath.Abs("../fixtures/schemas")
+	config := NewDefaultConfig()
+	config.FileName = "list_nested.yaml"
+	config.SchemaLocation = "file://" + schemaLocation
+
+	results, err := Validate(fileContents, config)
+	if err == nil || !strings.Contains(err.Error(), "Duplicate 'ReplicationController' resource 'first' in namespace 'default' at list_nested.yaml:19 (in items[1] of List), first defined at list_nested.yaml:9 (in items[0].items[0] of List)") {
+		t.Errorf("Expected the duplicate to be reported with its place in the List, got %v", err)
+	}
+
+	var expected = []struct {
+		Kind     string
+		Name     string
+		ListPath string
+		Line     int
+		Errors   int
+	}{
+		{Kind: "List", Line: 1},
+		{Kind: "ReplicationControllerList", ListPath: "items[0]", Line: 4},
+		{Kind: "ReplicationController", Name: "first", ListPath: "items[0].items[0]", Line: 9},
+		{Kind: "ReplicationController", Name: "second", ListPath: "items[0].items[1]", Line: 13, Errors: 1},
+		{Kind: "ReplicationController", Name: "first", ListPath: "items[1]", Line: 19},
+	}
+	if len(results) != len(expected) {
+		t.Fatalf("Expected %d results, got %d", len(expected), len(results))
+	}
+	for i, result := range results {
+		e := expected[i]
+		if result.Kind != e.Kind || result.ResourceName != e.Name || result.ListPath.String() != e.ListPath || result.Line != e.Line || len(result.Errors) != e.Errors {
+			t.Errorf("Expected %+v, got %s %s at %s on line %d with errors %v", e, result.Kind, result.ResourceName, result.ListPath, result.Line, result.Errors)
+		}
+	}
+	if results[0].ValidatedAgainstSchema {
+		t.Errorf("Expected the List without a schema not to be validated against one")
+	}
+	if kind := results[3].ListPath[0].Kind; kind != "List" {
+		t.Errorf("Expected the outermost List to come first in the path, got %s", kind)
+	}
+	if position := results[3].ErrorPositions[0]; position != (Position{Line: 18, Column: 17}) {
+		t.Errorf("Expected the error in the nested List at line 18, column 17, got %s", position)
+	}
+}
+
+func TestValidateListEnvelope(t *testing.T) {
+	schema, err := gojsonschema.NewSchema(gojsonschema.NewStringLoader(`{
+		"type": "object",
+		"required": ["metadata"],
+		"properties": {"items": {"type": "array", "maxItems": 0}}
+	}`))
+	if err != nil {
+		t.Fatalf("Unexpected error compiling schema: %s", err.Error())
+	}
+	config := NewDefaultConfig()
+	config.SchemaProvider = &staticSchemaProvider{kind: "ConfigMapList", schema: schema}
+	config.IgnoreMissingSchemas = true
+
+	// Items merged into the List with << are stubbed out in the same way
+	inputs := []string{`apiVersion: v1
+kind: ConfigMapList
+items:
+- apiVersion: v1
+  kind: ConfigMap
+  metadata:
+    name: config
+`, `apiVersion: v1
+kind: ConfigMapList
+shared: &shared
+  items:
+  - apiVersion: v1
+    kind: ConfigMap
+    metadata:
+      name: config
+<<: *shared
+`}
+	for _, input := range inputs {
+		results, err := Validate([]byte(input), config)
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err.Error())
+		}
+		if len(results) != 2 {
+			t.Fatalf("Expected results for the List and its item, got %d", len(results))
+		}
+		// The items are stubbed out, so only the missing metadata is an error
+		if !results[0].ValidatedAgainstSchema || len(results[0].Errors) != 1 || results[0].Errors[0].Type() != "required" {
+			t.Errorf("Expected the List to be missing its metadata, got %v", results[0].Errors)
+		}
+		if results[1].Kind != "ConfigMap" || results[1].ListPath.String() != "items[0]" {
+			t.Errorf("Expected the ConfigMap from items[0], got %s from %s", results[1].Kind, results[1].ListPath)
+		}
+	}
+}
@@ -0,0 +1,327 @@
+package kubeval
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"sigs.k8s.io/yaml"
+)
+
+// openAPIDocument holds the schema definitions from a Kubernetes OpenAPI
+// v2 or v3 document, from which standalone JSON schemas are built for each
+// resource type
+type openAPIDocument struct {
+	definitions map[string]interface{}
+	// refPrefix is the prefix of every $ref to one of the definitions
+	refPrefix string
+	// location is where the document was read from
+	location string
+}
+
+// loadOpenAPIV2Spec reads a Kubernetes swagger.json (OpenAPI v2) document
+// from a remote URL or local path
+func loadOpenAPIV2Spec(ctx context.Context, location string, config *Config) (*openAPIDocument, error) {
+	body, err := readLocation(ctx, location, newDiskCache(config))
+	if err != nil {
+		return nil, err
+	}
+	var spec struct {
+		Definitions map[string]interface{} `json:"definitions"`
+	}
+	if err := unmarshalJSONOrYAML(body, &spec); err != nil {
+		return nil, err
+	}
+	if len(spec.Definitions) == 0 {
+		return nil, fmt.Errorf("No definitions found")
+	}
+	return &openAPIDocument{
+		definitions: spec.Definitions,
+		refPrefix:   "#/definitions/",
+		location:    location,
+	}, nil
+}
+
+// loadOpenAPIV3Document reads the Kubernetes OpenAPI v3 document which
+// describes the group and version of the given apiVersion, from a tree of
+// documents laid out as an API server serves them under /openapi/v3. A
+// local tree may name its documents with or without a .json extension.
+// It returns nil if there's no document for the group and version.
+func loadOpenAPIV3Document(ctx context.Context, location, apiVersion string, config *Config) (*openAPIDocument, error) {
+	group, version := splitAPIVersion(apiVersion)
+	documentPath := "apis/" + group + "/" + version
+	if group == "" {
+		documentPath = "api/" + version
+	}
+	documentURL := strings.TrimSuffix(location, "/") + "/" + documentPath
+
+	candidates := []string{documentURL}
+	if !isRemote(documentURL) {
+		candidates = append(candidates, documentURL+".json")
+	}
+	var body []byte
+	for _, candidate := range candidates {
+		var err error
+		body, err = readLocation(ctx, candidate, newDiskCache(config))
+		if err == nil {
+			documentURL = candidate
+			break
+		}
+		if !isNotFound(err) {
+			return nil, err
+		}
+	}
+	if body == nil {
+		return nil, nil
+	}
+
+	var document struct {
+		Components struct {
+			Schemas map[string]interface{} `json:"schemas"`
+		} `json:"components"`
+	}
+	if err := unmarshalJSONOrYAML(body, &document); err != nil {
+		return nil, fmt.Errorf("%s: %s", documentURL, err)
+	}
+	return &openAPIDocument{
+		definitions: document.Components.Schemas,
+		refPrefix:   "#/components/schemas/",
+		location:    documentURL,
+	}, nil
+}
+
+// schema returns a standalone JSON schema for resources with the given
+// apiVersion and kind, or nil if the document doesn't define them
+func (d *openAPIDocument) schema(apiVersion, kind string) map[string]interface{} {
+	group, version := splitAPIVersion(apiVersion)
+
+	// Sort the definitions so that the result doesn't depend on the
+	// order of map iteration
+	names := make([]string, 0, len(d.definitions))
+	for name := range d.definitions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		definition, ok := d.definitions[name].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		gvks, _ := definition["x-kubernetes-group-version-kind"].([]interface{})
+		for _, g := range gvks {
+			gvk, ok := g.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			if gvk["group"] == group && gvk["version"] == version && gvk["kind"] == kind {
+				schema, _ := d.expand(definition, []string{name}).(map[string]interface{})
+				return schema
+			}
+		}
+	}
+	return nil
+}
+
+// expand resolves every $ref within node against the document's
+// definitions, and rewrites the Kubernetes extensions to OpenAPI into
+// their JSON schema equivalents. Recursive references, such as those
+// within CustomResourceDefinitions, are replaced with an empty schema.
+func (d *openAPIDocument) expand(node interface{}, seen []string) interface{} {
+	switch typed := node.(type) {
+	case map[string]interface{}:
+		if ref, ok := typed["$ref"].(string); ok && strings.HasPrefix(ref, d.refPrefix) {
+			name := strings.TrimPrefix(ref, d.refPrefix)
+			definition, ok := d.definitions[name]
+			if !ok || in(seen, name) {
+				return map[string]interface{}{}
+			}
+			expanded := d.expand(definition, append(append([]string{}, seen...), name))
+			if schema, ok := expanded.(map[string]interface{}); ok && strings.HasSuffix(name, "apimachinery.pkg.api.resource.Quantity") {
+				return quantitySchema(schema)
+			}
+			return expanded
+		}
+
+		expanded := make(map[string]interface{}, len(typed))
+		for k, v := range typed {
+			expanded[k] = d.expand(v, seen)
+		}
+		return kubernetesToJSONSchema(expanded)
+	case []interface{}:
+		expanded := make([]interface{}, len(typed))
+		for i, v := range typed {
+			expanded[i] = d.expand(v, seen)
+		}
+		return expanded
+	default:
+		return node
+	}
+}
+
+// kubernetesToJSONSchema rewrites a single schema node using the
+// extensions Kubernetes makes to OpenAPI into plain JSON schema, in the
+// same way as the schemas published for kubeval
+func kubernetesToJSONSchema(schema map[string]interface{}) map[string]interface{} {
+	// OpenAPI v3 documents wrap references in a single allOf so that they
+	// can carry a default, which would otherwise report every error twice
+	if allOf, ok := schema["allOf"].([]interface{}); ok && len(allOf) == 1 {
+		if subSchema, ok := allOf[0].(map[string]interface{}); ok {
+			merged := make(map[string]interface{}, len(subSchema)+len(schema))
+			for k, v := range subSchema {
+				merged[k] = v
+			}
+			for k, v := range schema {
+				if k != "allOf" {
+					merged[k] = v
+				}
+			}
+			schema = merged
+		}
+	}
+
+	intOrString, _ := schema["x-kubernetes-int-or-string"].(bool)
+	if schema["format"] == "int-or-string" || intOrString {
+		delete(schema, "type")
+		schema["format"] = "int-or-string"
+		schema["oneOf"] = []interface{}{
+			map[string]interface{}{"type": "string"},
+			map[string]interface{}{"type": "integer"},
+		}
+	}
+
+	if nullable, _ := schema["nullable"].(bool); nullable {
+		delete(schema, "nullable")
+		allowNull(schema)
+	}
+
+	// The API server treats null as unset, so only required properties
+	// must not be null
+	if properties, ok := schema["properties"].(map[string]interface{}); ok {
+		required, _ := schema["required"].([]interface{})
+		for name, property := range properties {
+			propertySchema, ok := property.(map[string]interface{})
+			if !ok || containsValue(required, name) {
+				continue
+			}
+			allowNull(propertySchema)
+		}
+	}
+	return schema
+}
+
+// quantitySchema accepts both the string and numeric forms of a
+// Kubernetes resource quantity
+func quantitySchema(schema map[string]interface{}) map[string]interface{} {
+	delete(schema, "type")
+	delete(schema, "anyOf")
+	schema["oneOf"] = []interface{}{
+		map[string]interface{}{"type": "string"},
+		map[string]interface{}{"type": "number"},
+	}
+	schema["format"] = "quantity"
+	return schema
+}
+
+// markQuantities adds the quantity format to every schema within node
+// which accepts one of a string or a number, and has no format of its
+// own, as that is how the schemas published for kubeval describe a
+// Kubernetes resource quantity
+func markQuantities(node interface{}) {
+	switch typed := node.(type) {
+	case map[string]interface{}:
+		if _, ok := typed["format"]; !ok && isQuantitySchema(typed) {
+			typed["format"] = "quantity"
+		}
+		for _, v := range typed {
+			markQuantities(v)
+		}
+	case []interface{}:
+		for _, v := range typed {
+			markQuantities(v)
+		}
+	}
+}
+
+// isQuantitySchema returns whether the schema is a oneOf between a string
+// and a number, either of which may also be null
+func isQuantitySchema(schema map[string]interface{}) bool {
+	oneOf, ok := schema["oneOf"].([]interface{})
+	if !ok || len(oneOf) != 2 {
+		return false
+	}
+	types := map[string]bool{}
+	for _, subSchema := range oneOf {
+		typed, ok := subSchema.(map[string]interface{})
+		if !ok {
+			return false
+		}
+		t := nonNullType(typed["type"])
+		if t == "" {
+			return false
+		}
+		types[t] = true
+	}
+	return types["string"] && types["number"]
+}
+
+// nonNullType returns the single type other than null allowed by a
+// schema's type keyword, or an empty string if there isn't exactly one
+func nonNullType(t interface{}) string {
+	switch typed := t.(type) {
+	case string:
+		if typed != "null" {
+			return typed
+		}
+	case []interface{}:
+		found := ""
+		for _, v := range typed {
+			name, ok := v.(string)
+			if !ok || (name != "null" && found != "") {
+				return ""
+			}
+			if name != "null" {
+				found = name
+			}
+		}
+		return found
+	}
+	return ""
+}
+
+// allowNull adds null to the types accepted by a schema
+func allowNull(schema map[string]interface{}) {
+	if t, ok := schema["type"].(string); ok && t != "null" {
+		schema["type"] = []interface{}{t, "null"}
+	}
+}
+
+// splitAPIVersion splits an apiVersion into its group and version, where
+// the core group is the empty string
+func splitAPIVersion(apiVersion string) (string, string) {
+	if i := strings.LastIndex(apiVersion, "/"); i >= 0 {
+		return apiVersion[:i], apiVersion[i+1:]
+	}
+	return "", apiVersion
+}
+
+// unmarshalJSONOrYAML decodes a document which may be JSON or YAML,
+// avoiding the slower YAML decoder for JSON documents
+func unmarshalJSONOrYAML(body []byte, v interface{}) error {
+	if trimmed := bytes.TrimSpace(body); len(trimmed) > 0 && trimmed[0] == '{' {
+		return json.Unmarshal(body, v)
+	}
+	return yaml.Unmarshal(body, v)
+}
+
+func containsValue(set []interface{}, value interface{}) bool {
+	for _, v := range set {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,219 @@
+package kubeval
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateAgainstOpenAPISpec(t *testing.T) {
+	var tests = []struct {
+		Fixture        string
+		Strict         bool
+		ExpectedErrors int
+	}{
+		{
+			Fixture: "valid.yaml",
+		},
+		{
+			Fixture: "int_or_string.yaml",
+		},
+		{
+			Fixture:        "invalid.yaml",
+			ExpectedErrors: 1,
+		},
+		{
+			// also reports the misspelt spec.templates
+			Fixture:        "invalid.yaml",
+			Strict:         true,
+			ExpectedErrors: 2,
+		},
+	}
+	for _, test := range tests {
+		config := NewDefaultConfig()
+		config.FileName = test.Fixture
+		config.Strict = test.Strict
+		config.OpenAPISpec = "../fixtures/openapi/swagger.json"
+		// make sure nothing is downloaded
+		config.SchemaLocation = "file:///nonexistent"
+		filePath, _ := filepath.Abs("../fixtures/" + test.Fixture)
+		fileContents, _ := ioutil.ReadFile(filePath)
+		results, err := Validate(fileContents, config)
+		if err != nil {
+			t.Errorf("Unexpected error for %s: %s", test.Fixture, err.Error())
+			continue
+		}
+		if !results[0].ValidatedAgainstSchema {
+			t.Errorf("%s should be validated against a schema from the OpenAPI spec", test.Fixture)
+		}
+		if len(results[0].Errors) != test.ExpectedErrors {
+			t.Errorf("%s with strict=%t should have %d errors, got: %v", test.Fixture, test.Strict, test.ExpectedErrors, results[0].Errors)
+		}
+	}
+}
+
+func TestOpenAPIRecursiveReferences(t *testing.T) {
+	spec, err := loadOpenAPIV2Spec(context.Background(), "../fixtures/openapi/swagger.json", NewDefaultConfig())
+	if err != nil {
+		t.Fatalf("Unexpected error loading spec: %s", err.Error())
+	}
+	schema := spec.expand(map[string]interface{}{
+		"$ref": "#/definitions/io.k8s.apiextensions-apiserver.pkg.apis.apiextensions.v1.JSONSchemaProps",
+	}, nil).(map[string]interface{})
+	items := schema["properties"].(map[string]interface{})["items"].(map[string]interface{})
+	if len(items) != 0 {
+		t.Errorf("Recursive references should be replaced with an empty schema, got %v", items)
+	}
+	if spec.schema("v1", "Pod") != nil {
+		t.Errorf("Kinds not defined in the spec should have no schema")
+	}
+}
+
+func TestValidateAgainstOpenAPIV3Documents(t *testing.T) {
+	// Serve the documents in the same way as an API server, without an extension
+	files := http.FileServer(http.Dir("../fixtures/openapi/v3"))
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.URL.Path += ".json"
+		files.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	invalidServiceType := []byte(`apiVersion: v1
+kind: Service
+metadata:
+  name: web
+spec:
+  type: Cluster
+  ports:
+  - port: 80
+    targetPort: http
+`)
+	validContents, _ := ioutil.ReadFile("../fixtures/valid.yaml")
+	invalidContents, _ := ioutil.ReadFile("../fixtures/invalid.yaml")
+	nullableContents, _ := ioutil.ReadFile("../fixtures/int_or_string.yaml")
+
+	var tests = []struct {
+		Name           string
+		Location       string
+		Input          []byte
+		Strict         bool
+		ExpectedErrors int
+	}{
+		{
+			Name:     "directory",
+			Location: "../fixtures/openapi/v3",
+			Input:    validContents,
+		},
+		{
+			Name:     "server",
+			Location: server.URL,
+			Input:    validContents,
+		},
+		{
+			Name:     "int_or_string",
+			Location: server.URL,
+			Input:    nullableContents,
+		},
+		{
+			Name:           "invalid",
+			Location:       "../fixtures/openapi/v3",
+			Input:          invalidContents,
+			ExpectedErrors: 1,
+		},
+		{
+			Name:           "invalid_strict",
+			Location:       server.URL,
+			Input:          invalidContents,
+			Strict:         true,
+			ExpectedErrors: 2,
+		},
+		{
+			Name:           "enum",
+			Location:       server.URL,
+			Input:          invalidServiceType,
+			ExpectedErrors: 1,
+		},
+	}
+	for _, test := range tests {
+		config := NewDefaultConfig()
+		config.FileName = test.Name
+		config.Strict = test.Strict
+		config.OpenAPIV3Location = test.Location
+		config.SchemaLocation = "file:///nonexistent"
+		results, err := Validate(test.Input, config)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", test.Name, err.Error())
+			continue
+		}
+		if !results[0].ValidatedAgainstSchema {
+			t.Errorf("%s: resource should be validated against a schema from the OpenAPI v3 documents", test.Name)
+		}
+		if len(results[0].Errors) != test.ExpectedErrors {
+			t.Errorf("%s: expected %d errors, got: %v", test.Name, test.ExpectedErrors, results[0].Errors)
+		}
+	}
+
+	// Groups without a document fall back to the schema locations
+	config := NewDefaultConfig()
+	config.OpenAPIV3Location = server.URL
+	config.SchemaLocation = "file:///nonexistent"
+	_, err := Validate([]byte("apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: web\n"), config)
+	if err == nil {
+		t.Errorf("Validate should fail for groups with neither a document nor a schema")
+	}
+}
+
+func TestOpenAPIV3DocumentsReadConcurrently(t *testing.T) {
+	var mu sync.Mutex
+	requests := make(map[string]int)
+	var inflight, maxInflight int32
+	files := http.FileServer(http.Dir("../fixtures/openapi/v3"))
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests[r.URL.Path]++
+		mu.Unlock()
+		current := atomic.AddInt32(&inflight, 1)
+		defer atomic.AddInt32(&inflight, -1)
+		for {
+			max := atomic.LoadInt32(&maxInflight)
+			if current <= max || atomic.CompareAndSwapInt32(&maxInflight, max, current) {
+				break
+			}
+		}
+		time.Sleep(100 * time.Millisecond)
+		r.URL.Path += ".json"
+		files.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	config := NewDefaultConfig()
+	config.NoCache = true
+	provider := NewOpenAPIV3SchemaProvider(server.URL, config).(*openAPIV3SchemaProvider)
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		for _, apiVersion := range []string{"v1", "apps/v1"} {
+			wg.Add(1)
+			go func(apiVersion string) {
+				defer wg.Done()
+				document, err := provider.document(context.Background(), apiVersion)
+				if err != nil {
+					t.Errorf("Unexpected error for %s: %s", apiVersion, err.Error())
+				} else if (document != nil) != (apiVersion == "v1") {
+					t.Errorf("Expected a document only for v1, got %v for %s", document, apiVersion)
+				}
+			}(apiVersion)
+		}
+	}
+	wg.Wait()
+
+	assert.Equal(t, map[string]int{"/api/v1": 1, "/apis/apps/v1": 1}, requests)
+	assert.Equal(t, int32(2), maxInflight, "documents for different groups and versions should be read at the same time")
+}
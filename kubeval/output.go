@@ -3,11 +3,16 @@ package kubeval
 import (
 	"bytes"
 	"encoding/json"
+	"encoding/xml"
 	"fmt"
 	"log"
+	"net/url"
 	"os"
+	"path/filepath"
+	"strings"
 
 	kLog "github.com/instrumenta/kubeval/log"
+	"github.com/instrumenta/kubeval/version"
 )
 
 // TODO (brendanryan) move these structs to `/log` once we have removed the potential
@@ -19,13 +24,19 @@ import (
 // this package.
 type outputManager interface {
 	Put(r ValidationResult) error
+	// PutError records an error which stopped a file being validated,
+	// such as those returned by ValidateWithCache
+	PutError(fileName string, err error) error
 	Flush() error
 }
 
 const (
-	outputSTD  = "stdout"
-	outputJSON = "json"
-	outputTAP  = "tap"
+	outputSTD   = "stdout"
+	outputJSON  = "json"
+	outputTAP   = "tap"
+	outputJUnit = "junit"
+	outputSARIF = "sarif"
+	outputJSONL = "jsonl"
 )
 
 func validOutputs() []string {
@@ -33,9 +44,38 @@ func validOutputs() []string {
 		outputSTD,
 		outputJSON,
 		outputTAP,
+		outputJUnit,
+		outputSARIF,
+		outputJSONL,
 	}
 }
 
+// The versions of the format of JSON output. Version 1 is a flat array of
+// results, whose errors are strings. Version 2 is a report which records
+// its version, with the errors in each result described in full.
+const (
+	jsonFormatV1 = 1
+	jsonFormatV2 = 2
+)
+
+// NewOutputManager returns the output manager for config.OutputFormat,
+// which writes JSON in version config.JSONVersion of its format, or
+// version 1 if that isn't set
+func NewOutputManager(config *Config) (outputManager, error) {
+	if config.OutputFormat == outputJSON {
+		switch config.JSONVersion {
+		case 0, jsonFormatV1:
+			return newDefaultJSONOutputManager(), nil
+		case jsonFormatV2:
+			return newDefaultJSONReportOutputManager(), nil
+		}
+		return nil, fmt.Errorf("Unsupported JSON output version %d. Options are: %v", config.JSONVersion, []int{jsonFormatV1, jsonFormatV2})
+	}
+	return GetOutputManager(config.OutputFormat), nil
+}
+
+// GetOutputManager returns the output manager for the given format, which
+// writes JSON in version 1 of its format
 func GetOutputManager(outFmt string) outputManager {
 	switch outFmt {
 	case outputSTD:
@@ -44,6 +84,12 @@ func GetOutputManager(outFmt string) outputManager {
 		return newDefaultJSONOutputManager()
 	case outputTAP:
 		return newDefaultTAPOutputManager()
+	case outputJUnit:
+		return newDefaultJUnitOutputManager()
+	case outputSARIF:
+		return newDefaultSARIFOutputManager()
+	case outputJSONL:
+		return newDefaultJSONLinesOutputManager()
 	default:
 		return newSTDOutputManager()
 	}
@@ -60,17 +106,25 @@ func newSTDOutputManager() *STDOutputManager {
 
 func (s *STDOutputManager) Put(result ValidationResult) error {
 	if len(result.Errors) > 0 {
-		for _, desc := range result.Errors {
-			kLog.Warn(result.FileName, "contains an invalid", result.Kind, fmt.Sprintf("(%s)", result.QualifiedName()), "-", desc.String())
+		for i := range result.Errors {
+			kLog.Warn(result.FileName, "contains an invalid", result.Kind, describeResource(result), "-", describeError(result, i))
 		}
 	} else if result.Kind == "" {
 		kLog.Success(result.FileName, "contains an empty YAML document")
 	} else if !result.ValidatedAgainstSchema {
-		kLog.Warn(result.FileName, "containing a", result.Kind, fmt.Sprintf("(%s)", result.QualifiedName()), "was not validated against a schema")
+		kLog.Warn(result.FileName, "containing a", result.Kind, describeResource(result), "was not validated against a schema")
 	} else {
-		kLog.Success(result.FileName, "contains a valid", result.Kind, fmt.Sprintf("(%s)", result.QualifiedName()))
+		kLog.Success(result.FileName, "contains a valid", result.Kind, describeResource(result))
 	}
+	for _, warning := range result.Warnings {
+		kLog.Warn(result.FileName, "containing a", result.Kind, describeResource(result), "-", warning)
+	}
+
+	return nil
+}
 
+func (s *STDOutputManager) PutError(fileName string, err error) error {
+	kLog.Error(err)
 	return nil
 }
 
@@ -94,6 +148,38 @@ type dataEvalResult struct {
 	Errors   []string `json:"errors"`
 }
 
+// describeResource returns the qualified name of a result's resource,
+// followed by the List item it was read from, if any
+func describeResource(r ValidationResult) string {
+	if len(r.ListPath) > 0 {
+		return fmt.Sprintf("(%s) in %s", r.QualifiedName(), describeListPath(r.ListPath))
+	}
+	return fmt.Sprintf("(%s)", r.QualifiedName())
+}
+
+// errorPosition returns the position in the input of the i'th error of a
+// result, or the zero Position if it isn't known
+func errorPosition(r ValidationResult, i int) Position {
+	if i < len(r.ErrorPositions) {
+		return r.ErrorPositions[i]
+	}
+	return Position{}
+}
+
+// describeError returns the description of the i'th error of a result,
+// in the same way as kubectl if possible, followed by where it is in the
+// input when that is known
+func describeError(r ValidationResult, i int) string {
+	description := r.Errors[i].String()
+	if i < len(r.FieldErrors) {
+		description = r.FieldErrors[i].String()
+	}
+	if position := errorPosition(r, i); position.IsValid() {
+		return fmt.Sprintf("%s (%s)", description, position)
+	}
+	return description
+}
+
 // jsonOutputManager reports `ccheck` results to `stdout` as a json array..
 type jsonOutputManager struct {
 	logger *log.Logger
@@ -146,6 +232,12 @@ func (j *jsonOutputManager) Put(r ValidationResult) error {
 	return nil
 }
 
+// PutError logs the error, as it has no place in the JSON array
+func (j *jsonOutputManager) PutError(fileName string, err error) error {
+	kLog.Error(err)
+	return nil
+}
+
 func (j *jsonOutputManager) Flush() error {
 	b, err := json.Marshal(j.data)
 	if err != nil {
@@ -162,6 +254,272 @@ func (j *jsonOutputManager) Flush() error {
 	return nil
 }
 
+// jsonReport is the JSON output in version 2 of its format
+type jsonReport struct {
+	Version int          `json:"version"`
+	Results []jsonResult `json:"results"`
+	// Errors are those which stopped files being validated
+	Errors []jsonFileError `json:"errors"`
+}
+
+// jsonResult describes a resource in version 2 of the JSON output
+type jsonResult struct {
+	Filename string `json:"filename"`
+	// DocumentIndex and Line are only known, and so only output, for
+	// resources read from a YAML document
+	DocumentIndex *int     `json:"documentIndex,omitempty"`
+	Line          int      `json:"line,omitempty"`
+	ListPath      ListPath `json:"listPath,omitempty"`
+	APIVersion    string   `json:"apiVersion"`
+	Kind          string   `json:"kind"`
+	Name          string   `json:"name,omitempty"`
+	Namespace     string   `json:"namespace,omitempty"`
+	Status        status   `json:"status"`
+	// Schema is the URL or path of the schema the resource was validated
+	// against, if it's known
+	Schema   string      `json:"schema,omitempty"`
+	Errors   []jsonError `json:"errors"`
+	Warnings []string    `json:"warnings,omitempty"`
+}
+
+// jsonError describes an error in version 2 of the JSON output
+type jsonError struct {
+	// Field is the path to the value the error is about, written in the
+	// same way as kubectl
+	Field string `json:"field"`
+	// Type is the type of error, such as required or invalid_type
+	Type        string `json:"type"`
+	Description string `json:"description"`
+	// Value is the value the error is about, which is left out if it's an
+	// object or array
+	Value      interface{} `json:"value,omitempty"`
+	Code       string      `json:"code"`
+	Message    string      `json:"message"`
+	Suggestion string      `json:"suggestion,omitempty"`
+	Line       int         `json:"line,omitempty"`
+	Column     int         `json:"column,omitempty"`
+}
+
+// jsonFileError describes an error which stopped a file being validated
+// in version 2 of the JSON output
+type jsonFileError struct {
+	Filename string        `json:"filename"`
+	Category ErrorCategory `json:"category"`
+	Message  string        `json:"message"`
+	Line     int           `json:"line,omitempty"`
+	Column   int           `json:"column,omitempty"`
+}
+
+// newJSONResult describes a result in version 2 of the JSON output
+func newJSONResult(r ValidationResult) jsonResult {
+	result := jsonResult{
+		Filename:   r.FileName,
+		ListPath:   r.ListPath,
+		APIVersion: r.APIVersion,
+		Kind:       r.Kind,
+		Name:       r.ResourceName,
+		Namespace:  r.ResourceNamespace,
+		Status:     getStatus(r),
+		Schema:     r.SchemaLocation,
+		Errors:     make([]jsonError, 0, len(r.Errors)),
+		Warnings:   r.Warnings,
+	}
+	if r.Line > 0 {
+		documentIndex := r.DocumentIndex
+		result.DocumentIndex = &documentIndex
+		result.Line = r.Line
+	}
+	for i, err := range r.Errors {
+		var fieldErr FieldError
+		if i < len(r.FieldErrors) {
+			fieldErr = r.FieldErrors[i]
+		} else {
+			fieldErr = NewFieldError(err)
+		}
+		position := errorPosition(r, i)
+		result.Errors = append(result.Errors, jsonError{
+			Field:       fieldErr.Field,
+			Type:        err.Type(),
+			Description: err.Description(),
+			Value:       jsonErrorValue(err.Value()),
+			Code:        fieldErr.Code,
+			Message:     fieldErr.Message,
+			Suggestion:  fieldErr.Suggestion,
+			Line:        position.Line,
+			Column:      position.Column,
+		})
+	}
+	return result
+}
+
+// newJSONFileError describes an error which stopped a file being
+// validated in version 2 of the JSON output
+func newJSONFileError(err *ValidationError) jsonFileError {
+	return jsonFileError{
+		Filename: err.FileName,
+		Category: err.Category,
+		Message:  err.Error(),
+		Line:     err.Position.Line,
+		Column:   err.Position.Column,
+	}
+}
+
+// jsonErrorValue returns the value an error is about if it's a scalar, or
+// nil for objects and arrays, which may be far larger than the error
+func jsonErrorValue(value interface{}) interface{} {
+	switch value.(type) {
+	case map[string]interface{}, []interface{}:
+		return nil
+	}
+	return value
+}
+
+// jsonReportOutputManager reports `kubeval` results to stdout as version 2
+// of the JSON output
+type jsonReportOutputManager struct {
+	logger *log.Logger
+
+	report jsonReport
+}
+
+// newDefaultJSONReportOutputManager instantiates a new instance of
+// jsonReportOutputManager using the default logger.
+func newDefaultJSONReportOutputManager() *jsonReportOutputManager {
+	return newJSONReportOutputManager(log.New(os.Stdout, "", 0))
+}
+
+// newJSONReportOutputManager constructs an instance of
+// jsonReportOutputManager given a logger instance.
+func newJSONReportOutputManager(l *log.Logger) *jsonReportOutputManager {
+	return &jsonReportOutputManager{
+		logger: l,
+		report: jsonReport{
+			Version: jsonFormatV2,
+			Results: []jsonResult{},
+			Errors:  []jsonFileError{},
+		},
+	}
+}
+
+func (j *jsonReportOutputManager) Put(r ValidationResult) error {
+	j.report.Results = append(j.report.Results, newJSONResult(r))
+	return nil
+}
+
+// PutError records each of the errors in the report
+func (j *jsonReportOutputManager) PutError(fileName string, err error) error {
+	for _, validationErr := range validationErrors(fileName, err) {
+		j.report.Errors = append(j.report.Errors, newJSONFileError(validationErr))
+	}
+	return nil
+}
+
+func (j *jsonReportOutputManager) Flush() error {
+	b, err := json.MarshalIndent(j.report, "", "\t")
+	if err != nil {
+		return err
+	}
+	j.logger.Print(string(b))
+	return nil
+}
+
+// The types of record in JSON Lines output
+const (
+	jsonLinesResult  = "result"
+	jsonLinesError   = "error"
+	jsonLinesSummary = "summary"
+)
+
+// jsonLinesResultRecord is a line of JSON Lines output describing a
+// resource in the same way as version 2 of the JSON output
+type jsonLinesResultRecord struct {
+	Type string `json:"type"`
+	jsonResult
+}
+
+// jsonLinesErrorRecord is a line of JSON Lines output describing an error
+// which stopped a file being validated
+type jsonLinesErrorRecord struct {
+	Type string `json:"type"`
+	jsonFileError
+}
+
+// jsonLinesSummaryRecord is the last line of JSON Lines output, counting
+// the records before it
+type jsonLinesSummaryRecord struct {
+	Type    string `json:"type"`
+	Results int    `json:"results"`
+	Valid   int    `json:"valid"`
+	Invalid int    `json:"invalid"`
+	Skipped int    `json:"skipped"`
+	Errors  int    `json:"errors"`
+}
+
+// jsonLinesOutputManager reports `kubeval` results to stdout as JSON
+// Lines, writing each as soon as it's known rather than holding them all
+// until the end
+type jsonLinesOutputManager struct {
+	logger *log.Logger
+
+	summary jsonLinesSummaryRecord
+}
+
+// newDefaultJSONLinesOutputManager instantiates a new instance of
+// jsonLinesOutputManager using the default logger.
+func newDefaultJSONLinesOutputManager() *jsonLinesOutputManager {
+	return newJSONLinesOutputManager(log.New(os.Stdout, "", 0))
+}
+
+// newJSONLinesOutputManager constructs an instance of
+// jsonLinesOutputManager given a logger instance.
+func newJSONLinesOutputManager(l *log.Logger) *jsonLinesOutputManager {
+	return &jsonLinesOutputManager{
+		logger:  l,
+		summary: jsonLinesSummaryRecord{Type: jsonLinesSummary},
+	}
+}
+
+// write writes a record on a line of its own
+func (j *jsonLinesOutputManager) write(record interface{}) error {
+	b, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	j.logger.Print(string(b))
+	return nil
+}
+
+func (j *jsonLinesOutputManager) Put(r ValidationResult) error {
+	result := newJSONResult(r)
+	j.summary.Results++
+	switch result.Status {
+	case statusValid:
+		j.summary.Valid++
+	case statusInvalid:
+		j.summary.Invalid++
+	case statusSkipped:
+		j.summary.Skipped++
+	}
+	return j.write(jsonLinesResultRecord{Type: jsonLinesResult, jsonResult: result})
+}
+
+// PutError writes a record for each of the errors
+func (j *jsonLinesOutputManager) PutError(fileName string, err error) error {
+	for _, validationErr := range validationErrors(fileName, err) {
+		j.summary.Errors++
+		record := jsonLinesErrorRecord{Type: jsonLinesError, jsonFileError: newJSONFileError(validationErr)}
+		if err := j.write(record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Flush writes the summary, as every result has already been written
+func (j *jsonLinesOutputManager) Flush() error {
+	return j.write(j.summary)
+}
+
 // tapOutputManager reports `conftest` results to stdout.
 type tapOutputManager struct {
 	logger *log.Logger
@@ -185,8 +543,8 @@ func newTAPOutputManager(l *log.Logger) *tapOutputManager {
 
 func (j *tapOutputManager) Put(r ValidationResult) error {
 	errs := make([]string, 0, len(r.Errors))
-	for _, e := range r.Errors {
-		errs = append(errs, e.String())
+	for i := range r.Errors {
+		errs = append(errs, describeError(r, i))
 	}
 
 	j.data = append(j.data, dataEvalResult{
@@ -199,6 +557,12 @@ func (j *tapOutputManager) Put(r ValidationResult) error {
 	return nil
 }
 
+// PutError logs the error, as it has no place in the TAP output
+func (j *tapOutputManager) PutError(fileName string, err error) error {
+	kLog.Error(err)
+	return nil
+}
+
 func (j *tapOutputManager) Flush() error {
 	issues := len(j.data)
 	if issues > 0 {
@@ -238,3 +602,348 @@ func (j *tapOutputManager) Flush() error {
 	}
 	return nil
 }
+
+// junitTestSuites is the root element of a JUnit XML report
+type junitTestSuites struct {
+	XMLName  xml.Name          `xml:"testsuites"`
+	Name     string            `xml:"name,attr"`
+	Tests    int               `xml:"tests,attr"`
+	Failures int               `xml:"failures,attr"`
+	Errors   int               `xml:"errors,attr"`
+	Skipped  int               `xml:"skipped,attr"`
+	Suites   []*junitTestSuite `xml:"testsuite"`
+}
+
+// junitTestSuite holds the results for a single file
+type junitTestSuite struct {
+	Name     string          `xml:"name,attr"`
+	Tests    int             `xml:"tests,attr"`
+	Failures int             `xml:"failures,attr"`
+	Errors   int             `xml:"errors,attr"`
+	Skipped  int             `xml:"skipped,attr"`
+	Cases    []junitTestCase `xml:"testcase"`
+}
+
+// junitTestCase holds the result for a single resource, or the error
+// which stopped a file being validated
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Error     *junitMessage `xml:"error,omitempty"`
+	Skipped   *junitMessage `xml:"skipped,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitMessage struct {
+	Message  string `xml:"message,attr,omitempty"`
+	Contents string `xml:",chardata"`
+}
+
+// junitOutputManager reports `kubeval` results to stdout as a JUnit XML
+// report, with a testsuite for each file and a testcase for each resource
+type junitOutputManager struct {
+	logger *log.Logger
+
+	suites []*junitTestSuite
+	byFile map[string]*junitTestSuite
+}
+
+// newDefaultJUnitOutputManager instantiates a new instance of
+// junitOutputManager using the default logger.
+func newDefaultJUnitOutputManager() *junitOutputManager {
+	return newJUnitOutputManager(log.New(os.Stdout, "", 0))
+}
+
+// newJUnitOutputManager constructs an instance of junitOutputManager given
+// a logger instance.
+func newJUnitOutputManager(l *log.Logger) *junitOutputManager {
+	return &junitOutputManager{
+		logger: l,
+		byFile: make(map[string]*junitTestSuite),
+	}
+}
+
+// suite returns the testsuite for a file, adding it if there is none
+func (j *junitOutputManager) suite(fileName string) *junitTestSuite {
+	suite, ok := j.byFile[fileName]
+	if !ok {
+		suite = &junitTestSuite{Name: fileName}
+		j.byFile[fileName] = suite
+		j.suites = append(j.suites, suite)
+	}
+	return suite
+}
+
+func (j *junitOutputManager) Put(r ValidationResult) error {
+	suite := j.suite(r.FileName)
+	// Empty documents hold no resource to report on
+	if r.Kind == "" {
+		return nil
+	}
+
+	testCase := junitTestCase{
+		Name:      r.VersionKind() + " " + describeResource(r),
+		ClassName: r.FileName,
+		SystemOut: strings.Join(r.Warnings, "\n"),
+	}
+	switch getStatus(r) {
+	case statusInvalid:
+		errs := make([]string, 0, len(r.Errors))
+		for i := range r.Errors {
+			errs = append(errs, describeError(r, i))
+		}
+		message := errs[0]
+		if len(errs) > 1 {
+			message = fmt.Sprintf("%d errors", len(errs))
+		}
+		testCase.Failure = &junitMessage{Message: message, Contents: strings.Join(errs, "\n")}
+		suite.Failures++
+	case statusSkipped:
+		testCase.Skipped = &junitMessage{Message: "not validated against a schema"}
+		suite.Skipped++
+	}
+	suite.Tests++
+	suite.Cases = append(suite.Cases, testCase)
+	return nil
+}
+
+// PutError records the error as a testcase of the file's testsuite
+func (j *junitOutputManager) PutError(fileName string, err error) error {
+	suite := j.suite(fileName)
+	suite.Tests++
+	suite.Errors++
+	suite.Cases = append(suite.Cases, junitTestCase{
+		Name:      fileName,
+		ClassName: fileName,
+		Error:     &junitMessage{Message: err.Error(), Contents: err.Error()},
+	})
+	return nil
+}
+
+func (j *junitOutputManager) Flush() error {
+	report := junitTestSuites{Name: "kubeval", Suites: j.suites}
+	for _, suite := range j.suites {
+		report.Tests += suite.Tests
+		report.Failures += suite.Failures
+		report.Errors += suite.Errors
+		report.Skipped += suite.Skipped
+	}
+
+	b, err := xml.MarshalIndent(report, "", "\t")
+	if err != nil {
+		return err
+	}
+	j.logger.Print(xml.Header + string(b))
+	return nil
+}
+
+// The rules of a SARIF report, one for each category of problem kubeval
+// finds
+const (
+	sarifSchemaViolation   = "schema-violation"
+	sarifYAMLCheck         = "yaml-check"
+	sarifMissingSchema     = "missing-schema"
+	sarifDuplicateResource = "duplicate-resource"
+	sarifProhibitedKind    = "prohibited-kind"
+	sarifParseError        = "parse-error"
+)
+
+// sarifRules describes each rule, in the order they're listed in a report
+var sarifRules = []sarifRule{
+	{ID: sarifSchemaViolation, Name: "SchemaViolation", ShortDescription: sarifText{"Resource doesn't match its schema"}, DefaultConfiguration: sarifConfiguration{"error"}},
+	{ID: sarifYAMLCheck, Name: "YAMLCheck", ShortDescription: sarifText{"Duplicate key, or YAML which decoders read differently"}, DefaultConfiguration: sarifConfiguration{"error"}},
+	{ID: sarifMissingSchema, Name: "MissingSchema", ShortDescription: sarifText{"No schema found for resource"}, DefaultConfiguration: sarifConfiguration{"warning"}},
+	{ID: sarifDuplicateResource, Name: "DuplicateResource", ShortDescription: sarifText{"Resource defined more than once"}, DefaultConfiguration: sarifConfiguration{"error"}},
+	{ID: sarifProhibitedKind, Name: "ProhibitedKind", ShortDescription: sarifText{"Resource of a prohibited kind"}, DefaultConfiguration: sarifConfiguration{"error"}},
+	{ID: sarifParseError, Name: "ParseError", ShortDescription: sarifText{"Input couldn't be read or decoded"}, DefaultConfiguration: sarifConfiguration{"error"}},
+}
+
+// yamlCheckTypes are the types of the errors reported by --check-yaml
+var yamlCheckTypes = []string{"duplicate_key", "yaml_boolean", "merge_key", "alias"}
+
+type sarifReport struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	Version        string      `json:"version"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID                   string             `json:"id"`
+	Name                 string             `json:"name"`
+	ShortDescription     sarifText          `json:"shortDescription"`
+	DefaultConfiguration sarifConfiguration `json:"defaultConfiguration"`
+}
+
+type sarifText struct {
+	Text string `json:"text"`
+}
+
+type sarifConfiguration struct {
+	Level string `json:"level"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId"`
+	RuleIndex int             `json:"ruleIndex"`
+	Level     string          `json:"level"`
+	Message   sarifText       `json:"message"`
+	Locations []sarifLocation `json:"locations"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           *sarifRegion          `json:"region,omitempty"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type sarifRegion struct {
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn,omitempty"`
+}
+
+// sarifOutputManager reports `kubeval` results to stdout as a SARIF log,
+// for code scanning tools
+type sarifOutputManager struct {
+	logger *log.Logger
+
+	results []sarifResult
+}
+
+// newDefaultSARIFOutputManager instantiates a new instance of
+// sarifOutputManager using the default logger.
+func newDefaultSARIFOutputManager() *sarifOutputManager {
+	return newSARIFOutputManager(log.New(os.Stdout, "", 0))
+}
+
+// newSARIFOutputManager constructs an instance of sarifOutputManager given
+// a logger instance.
+func newSARIFOutputManager(l *log.Logger) *sarifOutputManager {
+	return &sarifOutputManager{
+		logger:  l,
+		results: []sarifResult{},
+	}
+}
+
+// add records a result for the given rule at a position in a file
+func (s *sarifOutputManager) add(ruleID, level, message, fileName string, position Position) {
+	ruleIndex := 0
+	for i, rule := range sarifRules {
+		if rule.ID == ruleID {
+			ruleIndex = i
+		}
+	}
+	location := sarifLocation{PhysicalLocation: sarifPhysicalLocation{
+		ArtifactLocation: sarifArtifactLocation{URI: sarifURI(fileName)},
+	}}
+	if position.IsValid() {
+		location.PhysicalLocation.Region = &sarifRegion{StartLine: position.Line, StartColumn: position.Column}
+	}
+	s.results = append(s.results, sarifResult{
+		RuleID:    ruleID,
+		RuleIndex: ruleIndex,
+		Level:     level,
+		Message:   sarifText{message},
+		Locations: []sarifLocation{location},
+	})
+}
+
+func (s *sarifOutputManager) Put(r ValidationResult) error {
+	resource := r.VersionKind() + " " + describeResource(r)
+	for i, err := range r.Errors {
+		ruleID := sarifSchemaViolation
+		if in(yamlCheckTypes, err.Type()) {
+			ruleID = sarifYAMLCheck
+		}
+		description := err.String()
+		if i < len(r.FieldErrors) {
+			description = r.FieldErrors[i].String()
+		}
+		position := errorPosition(r, i)
+		if !position.IsValid() && r.Line > 0 {
+			position = Position{Line: r.Line, Column: 1}
+		}
+		s.add(ruleID, "error", resource+" - "+description, r.FileName, position)
+	}
+	// Resources which were skipped on purpose, such as the List holding
+	// other resources, aren't missing a schema
+	if r.Kind != "" && !r.ValidatedAgainstSchema && !r.skipped {
+		position := Position{}
+		if r.Line > 0 {
+			position = Position{Line: r.Line, Column: 1}
+		}
+		s.add(sarifMissingSchema, "warning", resource+" was not validated against a schema", r.FileName, position)
+	}
+	return nil
+}
+
+// PutError records each of the errors, under the rule for its category
+func (s *sarifOutputManager) PutError(fileName string, err error) error {
+	for _, validationErr := range validationErrors(fileName, err) {
+		s.add(string(validationErr.Category), "error", validationErr.Error(), validationErr.FileName, validationErr.Position)
+	}
+	return nil
+}
+
+func (s *sarifOutputManager) Flush() error {
+	toolVersion := version.BuildVersion
+	if toolVersion == "" {
+		toolVersion = "dev"
+	}
+	report := sarifReport{
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Version: "2.1.0",
+		Runs: []sarifRun{{
+			Tool: sarifTool{Driver: sarifDriver{
+				Name:           "kubeval",
+				Version:        toolVersion,
+				InformationURI: "https://kubeval.com",
+				Rules:          sarifRules,
+			}},
+			Results: s.results,
+		}},
+	}
+
+	b, err := json.MarshalIndent(report, "", "\t")
+	if err != nil {
+		return err
+	}
+	s.logger.Print(string(b))
+	return nil
+}
+
+// sarifURI returns the URI of a file, relative to the current directory
+// unless its name is absolute
+func sarifURI(fileName string) string {
+	uri := url.URL{Path: filepath.ToSlash(fileName)}
+	if filepath.IsAbs(fileName) {
+		uri.Scheme = "file"
+		if !strings.HasPrefix(uri.Path, "/") {
+			uri.Path = "/" + uri.Path
+		}
+	}
+	return uri.String()
+}
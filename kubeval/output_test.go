@@ -2,9 +2,14 @@ package kubeval
 
 import (
 	"bytes"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
 	"log"
+	"path/filepath"
 	"testing"
 
+	multierror "github.com/hashicorp/go-multierror"
 	"github.com/xeipuuv/gojsonschema"
 
 	"github.com/stretchr/testify/assert"
@@ -97,6 +102,37 @@ func Test_jsonOutputManager_put(t *testing.T) {
 		]
 	}
 ]
+`,
+		},
+		{
+			msg: "details added since version 1 are left out",
+			args: args{
+				vr: ValidationResult{
+					FileName:               "list.yaml",
+					Kind:                   "Service",
+					ValidatedAgainstSchema: true,
+					Errors: newResultErrors([]string{
+						"i am a error",
+					}),
+					DocumentIndex:  1,
+					Line:           12,
+					ErrorPositions: []Position{{Line: 15, Column: 7}},
+					ListPath:       ListPath{{Kind: "ServiceList", Index: 0}},
+					Warnings:       []string{"i am a warning"},
+					FieldErrors:    []FieldError{{Field: "error", Code: FieldValueInvalid, Message: "i am a error"}},
+					SchemaLocation: "file:///schemas/service-v1.json",
+				},
+			},
+			exp: `[
+	{
+		"filename": "list.yaml",
+		"kind": "Service",
+		"status": "invalid",
+		"errors": [
+			"error: i am a error"
+		]
+	}
+]
 `,
 		},
 	}
@@ -122,6 +158,53 @@ func Test_jsonOutputManager_put(t *testing.T) {
 	}
 }
 
+// Test_jsonOutputManager_golden checks that version 1 of the JSON output
+// is the same as that of releases before it was versioned
+func Test_jsonOutputManager_golden(t *testing.T) {
+	schemaLocation, _ := filepath.Abs("../fixtures/schemas")
+	buf := new(bytes.Buffer)
+	j := newJSONOutputManager(log.New(buf, "", 0))
+	for _, fixture := range []string{"invalid.yaml", "valid.yaml", "null_string.yaml"} {
+		config := NewDefaultConfig()
+		config.FileName = "fixtures/" + fixture
+		config.SchemaLocation = "file://" + schemaLocation
+		config.IgnoreMissingSchemas = true
+		fileContents, _ := ioutil.ReadFile("../fixtures/" + fixture)
+		results, err := Validate(fileContents, config)
+		if err != nil {
+			t.Fatalf("Unexpected error for %s: %s", fixture, err.Error())
+		}
+		for _, r := range results {
+			assert.NoError(t, j.Put(r))
+		}
+	}
+	assert.NoError(t, j.Flush())
+
+	assert.Equal(t, `[
+	{
+		"filename": "fixtures/invalid.yaml",
+		"kind": "ReplicationController",
+		"status": "invalid",
+		"errors": [
+			"spec.replicas: Invalid type. Expected: [integer,null], given: string"
+		]
+	},
+	{
+		"filename": "fixtures/valid.yaml",
+		"kind": "ReplicationController",
+		"status": "valid",
+		"errors": []
+	},
+	{
+		"filename": "fixtures/null_string.yaml",
+		"kind": "Service",
+		"status": "skipped",
+		"errors": []
+	}
+]
+`, buf.String())
+}
+
 func Test_tapOutputManager_put(t *testing.T) {
 	type args struct {
 		vr ValidationResult
@@ -177,6 +260,24 @@ not ok 2 - service.yaml (Service) - error: i am another error
 			},
 			exp: `1..1
 ok 1 - deployment.yaml (Deployment) # SKIP
+`,
+		},
+		{
+			msg: "file with error positions",
+			args: args{
+				vr: ValidationResult{
+					FileName:               "service.yaml",
+					Kind:                   "Service",
+					ValidatedAgainstSchema: true,
+					Errors: newResultErrors([]string{
+						"i am a error",
+					}),
+					Line:           12,
+					ErrorPositions: []Position{{Line: 15, Column: 7}},
+				},
+			},
+			exp: `1..1
+not ok 1 - service.yaml (Service) - error: i am a error (line 15, column 7)
 `,
 		},
 	}
@@ -201,3 +302,330 @@ ok 1 - deployment.yaml (Deployment) # SKIP
 		})
 	}
 }
+
+func Test_junitOutputManager(t *testing.T) {
+	buf := new(bytes.Buffer)
+	s := newJUnitOutputManager(log.New(buf, "", 0))
+
+	results := []ValidationResult{
+		{
+			FileName:               "deployment.yaml",
+			Kind:                   "Deployment",
+			APIVersion:             "apps/v1",
+			ResourceName:           "web",
+			ResourceNamespace:      "default",
+			ValidatedAgainstSchema: true,
+		},
+		{
+			FileName:               "deployment.yaml",
+			Kind:                   "Service",
+			APIVersion:             "v1",
+			ResourceName:           "web",
+			ValidatedAgainstSchema: true,
+			Errors: newResultErrors([]string{
+				"i am a error",
+				"i am another error",
+			}),
+			ErrorPositions: []Position{{Line: 15, Column: 7}},
+		},
+		{
+			FileName:   "deployment.yaml",
+			Kind:       "SealedSecret",
+			APIVersion: "bitnami.com/v1alpha1",
+		},
+		{
+			FileName: "deployment.yaml",
+		},
+	}
+	for _, r := range results {
+		assert.NoError(t, s.Put(r))
+	}
+	assert.NoError(t, s.PutError("broken.yaml", errors.New("Failed to decode YAML from broken.yaml")))
+	assert.NoError(t, s.Flush())
+
+	assert.Equal(t, `<?xml version="1.0" encoding="UTF-8"?>
+<testsuites name="kubeval" tests="4" failures="1" errors="1" skipped="1">
+	<testsuite name="deployment.yaml" tests="3" failures="1" errors="0" skipped="1">
+		<testcase name="apps/v1/Deployment (default.web)" classname="deployment.yaml"></testcase>
+		<testcase name="v1/Service (web)" classname="deployment.yaml">
+			<failure message="2 errors">error: i am a error (line 15, column 7)&#xA;error: i am another error</failure>
+		</testcase>
+		<testcase name="bitnami.com/v1alpha1/SealedSecret (unknown)" classname="deployment.yaml">
+			<skipped message="not validated against a schema"></skipped>
+		</testcase>
+	</testsuite>
+	<testsuite name="broken.yaml" tests="1" failures="0" errors="1" skipped="0">
+		<testcase name="broken.yaml" classname="broken.yaml">
+			<error message="Failed to decode YAML from broken.yaml">Failed to decode YAML from broken.yaml</error>
+		</testcase>
+	</testsuite>
+</testsuites>
+`, buf.String())
+}
+
+func Test_sarifOutputManager(t *testing.T) {
+	buf := new(bytes.Buffer)
+	s := newSARIFOutputManager(log.New(buf, "", 0))
+
+	assert.NoError(t, s.Put(ValidationResult{
+		FileName:               "manifests/service.yaml",
+		Kind:                   "Service",
+		APIVersion:             "v1",
+		ResourceName:           "web",
+		ValidatedAgainstSchema: true,
+		Errors:                 newResultErrors([]string{"i am a error"}),
+		Line:                   12,
+		ErrorPositions:         []Position{{Line: 15, Column: 7}},
+	}))
+	assert.NoError(t, s.Put(ValidationResult{
+		FileName:     "manifests/service.yaml",
+		Kind:         "SealedSecret",
+		APIVersion:   "bitnami.com/v1alpha1",
+		ResourceName: "secret",
+		Line:         20,
+	}))
+	var errs *multierror.Error
+	errs = multierror.Append(errs, &ValidationError{
+		Category: DuplicateResource,
+		FileName: "manifests/service.yaml",
+		Position: Position{Line: 30, Column: 1},
+		Err:      errors.New("Duplicate 'Service' resource 'web'"),
+	})
+	errs = multierror.Append(errs, errors.New("Could not open file"))
+	assert.NoError(t, s.PutError("manifests/other file.yaml", errs))
+	assert.NoError(t, s.Flush())
+
+	var report sarifReport
+	if err := json.Unmarshal(buf.Bytes(), &report); err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	assert.Equal(t, "2.1.0", report.Version)
+	assert.Equal(t, "kubeval", report.Runs[0].Tool.Driver.Name)
+	assert.Equal(t, "dev", report.Runs[0].Tool.Driver.Version)
+
+	type expectedResult struct {
+		RuleID  string
+		Level   string
+		Message string
+		URI     string
+		Region  *sarifRegion
+	}
+	var results []expectedResult
+	for _, r := range report.Runs[0].Results {
+		assert.Equal(t, r.RuleID, sarifRules[r.RuleIndex].ID)
+		location := r.Locations[0].PhysicalLocation
+		results = append(results, expectedResult{r.RuleID, r.Level, r.Message.Text, location.ArtifactLocation.URI, location.Region})
+	}
+	assert.Equal(t, []expectedResult{
+		{"schema-violation", "error", "v1/Service (web) - error: i am a error", "manifests/service.yaml", &sarifRegion{StartLine: 15, StartColumn: 7}},
+		{"missing-schema", "warning", "bitnami.com/v1alpha1/SealedSecret (secret) was not validated against a schema", "manifests/service.yaml", &sarifRegion{StartLine: 20, StartColumn: 1}},
+		{"duplicate-resource", "error", "Duplicate 'Service' resource 'web'", "manifests/service.yaml", &sarifRegion{StartLine: 30, StartColumn: 1}},
+		{"parse-error", "error", "Could not open file", "manifests/other%20file.yaml", nil},
+	}, results)
+}
+
+func Test_sarifOutputManager_skipped(t *testing.T) {
+	input := []byte(`apiVersion: v1
+kind: List
+items:
+- apiVersion: v1
+  kind: ReplicationController
+  metadata:
+    name: web
+- apiVersion: v1
+  kind: Secret
+  metadata:
+    name: token
+---
+apiVersion: bitnami.com/v1alpha1
+kind: SealedSecret
+metadata:
+  name: secret
+`)
+	schemaLocation, _ := filepath.Abs("../fixtures/schemas")
+	config := NewDefaultConfig()
+	config.FileName = "manifests.yaml"
+	config.SchemaLocation = "file://" + schemaLocation
+	config.IgnoreMissingSchemas = true
+	config.KindsToSkip = []string{"Secret"}
+	results, err := Validate(input, config)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+
+	buf := new(bytes.Buffer)
+	s := newSARIFOutputManager(log.New(buf, "", 0))
+	for _, r := range results {
+		assert.NoError(t, s.Put(r))
+	}
+	assert.NoError(t, s.Flush())
+
+	// Only the SealedSecret is missing a schema, as the List and the
+	// Secret weren't meant to be validated against one
+	var report sarifReport
+	if err := json.Unmarshal(buf.Bytes(), &report); err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	var messages []string
+	for _, r := range report.Runs[0].Results {
+		messages = append(messages, r.RuleID+": "+r.Message.Text)
+	}
+	assert.Equal(t, []string{"missing-schema: bitnami.com/v1alpha1/SealedSecret (secret) was not validated against a schema"}, messages)
+}
+
+func Test_jsonReportOutputManager(t *testing.T) {
+	buf := new(bytes.Buffer)
+	j := newJSONReportOutputManager(log.New(buf, "", 0))
+
+	assert.NoError(t, j.Put(ValidationResult{}))
+	assert.NoError(t, j.Put(ValidationResult{
+		FileName:               "service.yaml",
+		Kind:                   "Service",
+		APIVersion:             "v1",
+		ResourceName:           "web",
+		ResourceNamespace:      "prod",
+		ValidatedAgainstSchema: true,
+		SchemaLocation:         "file:///schemas/service-v1.json",
+		Errors: []gojsonschema.ResultError{
+			newTypedResultError("invalid_type", []string{"spec", "ports", "0", "port"}, "http",
+				gojsonschema.ErrorDetails{"expected": "integer", "given": "string"},
+				"Invalid type. Expected: integer, given: string"),
+			newTypedResultError("required", []string{"spec"}, map[string]interface{}{},
+				gojsonschema.ErrorDetails{"property": "selector"},
+				"selector is required"),
+		},
+		DocumentIndex:  1,
+		Line:           12,
+		ErrorPositions: []Position{{Line: 18, Column: 15}, {Line: 14, Column: 3}},
+	}))
+	assert.NoError(t, j.PutError("broken.yaml", &ValidationError{
+		Category: ParseError,
+		Position: Position{Line: 3},
+		Err:      errors.New("broken.yaml: line 3: mapping values are not allowed in this context"),
+	}))
+	assert.NoError(t, j.Flush())
+
+	assert.Equal(t, `{
+	"version": 2,
+	"results": [
+		{
+			"filename": "",
+			"apiVersion": "",
+			"kind": "",
+			"status": "skipped",
+			"errors": []
+		},
+		{
+			"filename": "service.yaml",
+			"documentIndex": 1,
+			"line": 12,
+			"apiVersion": "v1",
+			"kind": "Service",
+			"name": "web",
+			"namespace": "prod",
+			"status": "invalid",
+			"schema": "file:///schemas/service-v1.json",
+			"errors": [
+				{
+					"field": "spec.ports[0].port",
+					"type": "invalid_type",
+					"description": "Invalid type. Expected: integer, given: string",
+					"value": "http",
+					"code": "FieldValueTypeInvalid",
+					"message": "Invalid value: \"http\": expected integer, given string",
+					"line": 18,
+					"column": 15
+				},
+				{
+					"field": "spec.selector",
+					"type": "required",
+					"description": "selector is required",
+					"code": "FieldValueRequired",
+					"message": "Required value",
+					"line": 14,
+					"column": 3
+				}
+			]
+		}
+	],
+	"errors": [
+		{
+			"filename": "broken.yaml",
+			"category": "parse-error",
+			"message": "broken.yaml: line 3: mapping values are not allowed in this context",
+			"line": 3
+		}
+	]
+}
+`, buf.String())
+}
+
+func TestNewOutputManager(t *testing.T) {
+	for version, expected := range map[int]outputManager{
+		0:            &jsonOutputManager{},
+		jsonFormatV1: &jsonOutputManager{},
+		jsonFormatV2: &jsonReportOutputManager{},
+	} {
+		manager, err := NewOutputManager(&Config{OutputFormat: outputJSON, JSONVersion: version})
+		assert.NoError(t, err)
+		assert.IsType(t, expected, manager)
+	}
+
+	_, err := NewOutputManager(&Config{OutputFormat: outputJSON, JSONVersion: 3})
+	assert.EqualError(t, err, "Unsupported JSON output version 3. Options are: [1 2]")
+
+	assert.IsType(t, &jsonOutputManager{}, GetOutputManager(outputJSON))
+
+	manager, err := NewOutputManager(&Config{OutputFormat: outputTAP, JSONVersion: 3})
+	assert.NoError(t, err)
+	assert.IsType(t, &tapOutputManager{}, manager)
+}
+
+func Test_jsonLinesOutputManager(t *testing.T) {
+	buf := new(bytes.Buffer)
+	j := newJSONLinesOutputManager(log.New(buf, "", 0))
+
+	assert.NoError(t, j.Put(ValidationResult{
+		FileName:               "deployment.yaml",
+		Kind:                   "Deployment",
+		APIVersion:             "apps/v1",
+		ResourceName:           "web",
+		ValidatedAgainstSchema: true,
+	}))
+	// Results are written as soon as they're put
+	assert.Equal(t, `{"type":"result","filename":"deployment.yaml","apiVersion":"apps/v1","kind":"Deployment","name":"web","status":"valid","errors":[]}
+`, buf.String())
+
+	assert.NoError(t, j.Put(ValidationResult{
+		FileName:               "service.yaml",
+		Kind:                   "Service",
+		APIVersion:             "v1",
+		ValidatedAgainstSchema: true,
+		Errors: []gojsonschema.ResultError{
+			newTypedResultError("invalid_type", []string{"spec", "type"}, json.Number("1"),
+				gojsonschema.ErrorDetails{"expected": "string", "given": "integer"},
+				"Invalid type. Expected: string, given: integer"),
+		},
+		DocumentIndex: 2,
+		Line:          9,
+	}))
+	assert.NoError(t, j.Put(ValidationResult{FileName: "sealedsecret.yaml", Kind: "SealedSecret"}))
+	var errs *multierror.Error
+	errs = multierror.Append(errs, &ValidationError{
+		Category: DuplicateResource,
+		FileName: "service.yaml",
+		Position: Position{Line: 20, Column: 1},
+		Err:      errors.New("service.yaml: Duplicate 'Service' resource 'web' in namespace 'default'"),
+	})
+	errs = multierror.Append(errs, errors.New("Could not open file missing.yaml"))
+	assert.NoError(t, j.PutError("missing.yaml", errs))
+	assert.NoError(t, j.Flush())
+
+	assert.Equal(t, `{"type":"result","filename":"deployment.yaml","apiVersion":"apps/v1","kind":"Deployment","name":"web","status":"valid","errors":[]}
+{"type":"result","filename":"service.yaml","documentIndex":2,"line":9,"apiVersion":"v1","kind":"Service","status":"invalid","errors":[{"field":"spec.type","type":"invalid_type","description":"Invalid type. Expected: string, given: integer","value":1,"code":"FieldValueTypeInvalid","message":"Invalid value: 1: expected string, given integer"}]}
+{"type":"result","filename":"sealedsecret.yaml","apiVersion":"","kind":"SealedSecret","status":"skipped","errors":[]}
+{"type":"error","filename":"service.yaml","category":"duplicate-resource","message":"service.yaml: Duplicate 'Service' resource 'web' in namespace 'default'","line":20,"column":1}
+{"type":"error","filename":"missing.yaml","category":"parse-error","message":"Could not open file missing.yaml"}
+{"type":"summary","results":3,"valid":1,"invalid":1,"skipped":1,"errors":2}
+`, buf.String())
+}
@@ -0,0 +1,158 @@
+package kubeval
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/xeipuuv/gojsonschema"
+	yamlv3 "gopkg.in/yaml.v3"
+)
+
+// Position is a line and column in the input, both starting from 1. The
+// zero Position means that the location isn't known.
+type Position struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+// IsValid returns whether the position is known
+func (p Position) IsValid() bool {
+	return p.Line > 0
+}
+
+func (p Position) String() string {
+	return fmt.Sprintf("line %d, column %d", p.Line, p.Column)
+}
+
+// contextSeparator joins the fields of a gojsonschema context so that
+// they can be split apart again, as keys may themselves contain dots
+const contextSeparator = "\x00"
+
+// parseNode decodes a single YAML document into its node tree, returning
+// the root node, or nil if the document is empty
+func parseNode(data []byte) (*yamlv3.Node, error) {
+	var doc yamlv3.Node
+	if err := yamlv3.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	if doc.Kind != yamlv3.DocumentNode || len(doc.Content) == 0 {
+		return nil, nil
+	}
+	return doc.Content[0], nil
+}
+
+// errorPath returns the fields leading from the root of a resource to the
+// value an error is about
+func errorPath(err gojsonschema.ResultError) []string {
+	if err.Context() == nil {
+		return nil
+	}
+	path := strings.Split(err.Context().String(contextSeparator), contextSeparator)
+	if len(path) > 0 && path[0] == gojsonschema.STRING_CONTEXT_ROOT {
+		path = path[1:]
+	}
+	return path
+}
+
+// errorNode returns the node within root which an error is about. For
+// errors about a property which is present, that is its key.
+func errorNode(root *yamlv3.Node, err gojsonschema.ResultError) *yamlv3.Node {
+	node := findNode(root, errorPath(err))
+	switch err.Type() {
+	case "additional_property_not_allowed", "invalid_property_pattern", "invalid_property_name":
+		if property, ok := err.Details()["property"].(string); ok {
+			if key, _ := lookupNode(node, property); key != nil {
+				return key
+			}
+		}
+	}
+	return node
+}
+
+// findNode follows path from node through mappings and sequences,
+// returning the deepest node found along it
+func findNode(node *yamlv3.Node, path []string) *yamlv3.Node {
+	for _, field := range path {
+		_, value := lookupNode(node, field)
+		if value == nil {
+			break
+		}
+		node = value
+	}
+	return node
+}
+
+// lookupNode returns the key and value for a field of a mapping node,
+// including those merged into it with <<, or the item at an index of a
+// sequence node, in which case the key is nil
+func lookupNode(node *yamlv3.Node, field string) (*yamlv3.Node, *yamlv3.Node) {
+	node = resolveAlias(node)
+	if node == nil {
+		return nil, nil
+	}
+	switch node.Kind {
+	case yamlv3.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if node.Content[i].Value == field && node.Content[i].Tag != "!!merge" {
+				return node.Content[i], node.Content[i+1]
+			}
+		}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if node.Content[i].Tag != "!!merge" {
+				continue
+			}
+			merged := resolveAlias(node.Content[i+1])
+			sources := []*yamlv3.Node{merged}
+			if merged != nil && merged.Kind == yamlv3.SequenceNode {
+				sources = merged.Content
+			}
+			for _, source := range sources {
+				if key, value := lookupNode(source, field); value != nil {
+					return key, value
+				}
+			}
+		}
+	case yamlv3.SequenceNode:
+		if i, err := strconv.Atoi(field); err == nil && i >= 0 && i < len(node.Content) {
+			return nil, node.Content[i]
+		}
+	}
+	return nil, nil
+}
+
+// resolveAlias returns the node an alias refers to, or node itself
+func resolveAlias(node *yamlv3.Node) *yamlv3.Node {
+	for node != nil && node.Kind == yamlv3.AliasNode {
+		node = node.Alias
+	}
+	return node
+}
+
+// nodePosition returns the position of a node within a document which
+// starts at the given line of the input
+func nodePosition(node *yamlv3.Node, documentLine int) Position {
+	if node == nil || node.Line == 0 {
+		return Position{}
+	}
+	return Position{Line: documentLine + node.Line - 1, Column: node.Column}
+}
+
+// setPositions records where in the input a resource and each of its
+// errors are, using the node tree of the document it was read from
+func setPositions(result *ValidationResult, doc document) {
+	result.DocumentIndex = doc.index
+	doc.parse()
+	root := doc.node
+	if root == nil {
+		return
+	}
+	result.Line = nodePosition(root, doc.line).Line
+	if len(result.Errors) == 0 {
+		return
+	}
+	result.ErrorPositions = make([]Position, len(result.Errors))
+	for i, err := range result.Errors {
+		result.ErrorPositions[i] = nodePosition(errorNode(root, err), doc.line)
+	}
+}
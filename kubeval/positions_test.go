@@ -0,0 +1,114 @@
+package kubeval
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestErrorPositions(t *testing.T) {
+	var tests = []struct {
+		Name              string
+		Input             string
+		Strict            bool
+		ExpectedDocument  int
+		ExpectedLine      int
+		ExpectedPositions map[string]Position
+	}{
+		{
+			Name:         "invalid.yaml",
+			Strict:       true,
+			ExpectedLine: 1,
+			ExpectedPositions: map[string]Position{
+				"spec.replicas": {Line: 6, Column: 13},
+				// the key of the additional property
+				"templates": {Line: 9, Column: 3},
+			},
+		},
+		{
+			Name:         "list_invalid.yaml",
+			ExpectedLine: 21,
+			ExpectedPositions: map[string]Position{
+				"spec.replicas": {Line: 26, Column: 15},
+			},
+		},
+		{
+			Name: "second document",
+			Input: `---
+apiVersion: v1
+kind: ReplicationController
+metadata:
+  name: valid
+--- # an invalid controller
+apiVersion: v1
+kind: ReplicationController
+metadata:
+  name: invalid
+spec:
+  replicas: "2"
+`,
+			ExpectedDocument: 1,
+			ExpectedLine:     7,
+			ExpectedPositions: map[string]Position{
+				"spec.replicas": {Line: 12, Column: 13},
+			},
+		},
+		{
+			Name: "merge key",
+			Input: `apiVersion: v1
+kind: ReplicationController
+metadata:
+  name: merged
+spec:
+  <<: &defaults
+    replicas: two
+`,
+			ExpectedLine: 1,
+			ExpectedPositions: map[string]Position{
+				"spec.replicas": {Line: 7, Column: 15},
+			},
+		},
+	}
+	schemaLocation, _ := filepath.Abs("../fixtures/schemas")
+	for _, test := range tests {
+		config := NewDefaultConfig()
+		config.FileName = test.Name
+		config.Strict = test.Strict
+		config.IgnoreMissingSchemas = true
+		config.SchemaLocation = "file://" + schemaLocation
+		input := []byte(test.Input)
+		if test.Input == "" {
+			input, _ = ioutil.ReadFile("../fixtures/" + test.Name)
+		}
+		results, err := Validate(input, config)
+		if err != nil {
+			t.Errorf("Unexpected error for %s: %s", test.Name, err.Error())
+			continue
+		}
+		var result *ValidationResult
+		for i := range results {
+			if len(results[i].Errors) > 0 {
+				result = &results[i]
+			}
+		}
+		if result == nil {
+			t.Errorf("Expected errors for %s", test.Name)
+			continue
+		}
+		if result.DocumentIndex != test.ExpectedDocument || result.Line != test.ExpectedLine {
+			t.Errorf("Expected %s to be in document %d at line %d, got document %d at line %d", test.Name, test.ExpectedDocument, test.ExpectedLine, result.DocumentIndex, result.Line)
+		}
+		if len(result.ErrorPositions) != len(result.Errors) {
+			t.Errorf("Expected a position for each error in %s, got %v", test.Name, result.ErrorPositions)
+			continue
+		}
+		for i, e := range result.Errors {
+			expected, ok := test.ExpectedPositions[e.Field()]
+			if !ok {
+				t.Errorf("Unexpected error in %s: %s", test.Name, e.String())
+			} else if result.ErrorPositions[i] != expected {
+				t.Errorf("Expected %s in %s to be at %s, got %s", e.String(), test.Name, expected, result.ErrorPositions[i])
+			}
+		}
+	}
+}
@@ -0,0 +1,474 @@
+package kubeval
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"text/template"
+
+	"github.com/hashicorp/go-multierror"
+	"github.com/xeipuuv/gojsonschema"
+)
+
+// SchemaProvider finds the schema against which to validate a type of
+// Kubernetes resource. Schemas are cached by kubeval, so a provider is
+// asked for each apiVersion and kind at most once per schema cache.
+type SchemaProvider interface {
+	// Schema returns the schema for resources with the given apiVersion
+	// and kind, or an error describing why no schema could be found
+	Schema(ctx context.Context, apiVersion, kind string) (*gojsonschema.Schema, error)
+}
+
+// schemaLocator is implemented by schema providers which can say where
+// they found the schema for a type of resource, so that results can
+// record which schema they were validated against
+type schemaLocator interface {
+	// schemaLocation returns the URL or path the schema for resources of
+	// the given apiVersion and kind was read from, or "" if it isn't known
+	schemaLocation(apiVersion, kind string) string
+}
+
+// chainSchemaProvider asks each of a list of providers in turn
+type chainSchemaProvider struct {
+	providers []SchemaProvider
+
+	// found records which of the providers found the schema for each
+	// apiVersion and kind, keyed by versionKind
+	mu    sync.Mutex
+	found map[string]SchemaProvider
+}
+
+// NewChainSchemaProvider returns a SchemaProvider which returns the first
+// schema found by the given providers, in order. If none of them find a
+// schema, the errors from all of them are returned.
+func NewChainSchemaProvider(providers ...SchemaProvider) SchemaProvider {
+	return &chainSchemaProvider{providers: providers, found: make(map[string]SchemaProvider)}
+}
+
+func (c *chainSchemaProvider) Schema(ctx context.Context, apiVersion, kind string) (*gojsonschema.Schema, error) {
+	schema, _, err := c.documentedSchema(ctx, apiVersion, kind)
+	return schema, err
+}
+
+// documentedSchema returns the first schema found by the providers, along
+// with its document if the provider which found it read one
+func (c *chainSchemaProvider) documentedSchema(ctx context.Context, apiVersion, kind string) (*gojsonschema.Schema, interface{}, error) {
+	var errors *multierror.Error
+	for _, provider := range c.providers {
+		schema, document, err := findSchema(ctx, provider, apiVersion, kind)
+		if err == nil && schema != nil {
+			c.mu.Lock()
+			c.found[versionKind(apiVersion, kind)] = provider
+			c.mu.Unlock()
+			return schema, document, nil
+		}
+		if err == nil {
+			err = fmt.Errorf("No schema for %s", versionKind(apiVersion, kind))
+		}
+		errors = multierror.Append(errors, err)
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	if errors != nil {
+		errors.ErrorFormat = singleLineErrorFormat
+	}
+	return nil, nil, errors.ErrorOrNil()
+}
+
+// findSchema asks a provider for the schema for resources of the given
+// apiVersion and kind, and for the document it was compiled from if the
+// provider reads one
+func findSchema(ctx context.Context, provider SchemaProvider, apiVersion, kind string) (*gojsonschema.Schema, interface{}, error) {
+	if documented, ok := provider.(documentedSchemaProvider); ok {
+		return documented.documentedSchema(ctx, apiVersion, kind)
+	}
+	schema, err := provider.Schema(ctx, apiVersion, kind)
+	return schema, nil, err
+}
+
+// clusterScoped returns the scope known to the first of the providers
+// which knows it
+func (c *chainSchemaProvider) clusterScoped(apiVersion, kind string) (bool, bool) {
+	for _, provider := range c.providers {
+		if scopes, ok := provider.(scopeProvider); ok {
+			if clusterScoped, ok := scopes.clusterScoped(apiVersion, kind); ok {
+				return clusterScoped, true
+			}
+		}
+	}
+	return false, false
+}
+
+// schemaLocation returns the location given by the provider which found
+// the schema
+func (c *chainSchemaProvider) schemaLocation(apiVersion, kind string) string {
+	c.mu.Lock()
+	provider := c.found[versionKind(apiVersion, kind)]
+	c.mu.Unlock()
+	if locator, ok := provider.(schemaLocator); ok {
+		return locator.schemaLocation(apiVersion, kind)
+	}
+	return ""
+}
+
+// schemaDocument returns the document given by the provider which found
+// the schema
+func (c *chainSchemaProvider) schemaDocument(ctx context.Context, apiVersion, kind string) (interface{}, error) {
+	c.mu.Lock()
+	provider := c.found[versionKind(apiVersion, kind)]
+	c.mu.Unlock()
+	if documents, ok := provider.(schemaDocumentProvider); ok {
+		return documents.schemaDocument(ctx, apiVersion, kind)
+	}
+	return nil, nil
+}
+
+// NewDefaultSchemaProvider returns the SchemaProvider kubeval uses when
+// Config.SchemaProvider is not set. It searches, in order, the
+// CustomResourceDefinitions in CRDLocations, the OpenAPISpec, the
+// documents at OpenAPIV3Location, the primary schema location and then
+// each of the AdditionalSchemaLocations.
+func NewDefaultSchemaProvider(config *Config) SchemaProvider {
+	var providers []SchemaProvider
+	if len(config.CRDLocations) > 0 {
+		providers = append(providers, NewCRDSchemaProvider(config.CRDLocations, config))
+	}
+	if config.OpenAPISpec != "" {
+		providers = append(providers, NewOpenAPISchemaProvider(config.OpenAPISpec, config))
+	}
+	if config.OpenAPIV3Location != "" {
+		providers = append(providers, NewOpenAPIV3SchemaProvider(config.OpenAPIV3Location, config))
+	}
+	providers = append(providers, NewURLSchemaProvider(determineSchemaBaseURL(config), config))
+	for _, location := range config.AdditionalSchemaLocations {
+		providers = append(providers, NewURLSchemaProvider(location, config))
+	}
+	return NewChainSchemaProvider(providers...)
+}
+
+// urlSchemaProvider finds schemas laid out under a base URL or directory
+// in the same way as https://kubernetesjsonschema.dev, or at the URLs
+// given by a Go template
+type urlSchemaProvider struct {
+	baseURL string
+	config  *Config
+
+	template    *template.Template
+	templateErr error
+
+	// archive is the path of the bundle archive at baseURL, if it is one,
+	// whose files are read the first time a schema is needed
+	archive      string
+	archiveOnce  sync.Once
+	archiveFiles map[string][]byte
+	archiveErr   error
+}
+
+// NewURLSchemaProvider returns a SchemaProvider which downloads schemas
+// from a base URL, or reads them from a file:// location, using the
+// Kubernetes version, strictness and OpenShift settings from config. If
+// baseURL contains {{ it is instead parsed as a Go template giving the
+// URL of each schema. A local .tar.gz or .tgz baseURL is read as a bundle
+// archive written by PullSchemas.
+func NewURLSchemaProvider(baseURL string, config *Config) SchemaProvider {
+	provider := &urlSchemaProvider{baseURL: baseURL, config: config}
+	if isSchemaURLTemplate(baseURL) {
+		provider.template, provider.templateErr = parseSchemaURLTemplate(baseURL)
+	} else if !isRemote(baseURL) && isArchive(baseURL) {
+		provider.archive = strings.TrimPrefix(baseURL, "file://")
+	}
+	return provider
+}
+
+// schemaRef returns the location of the schema for resources with the
+// given apiVersion and kind
+func (u *urlSchemaProvider) schemaRef(apiVersion, kind string) (string, error) {
+	if u.templateErr != nil {
+		return "", fmt.Errorf("Invalid schema location template %s: %s", u.baseURL, u.templateErr)
+	}
+	if u.template != nil {
+		schemaRef, err := executeSchemaURLTemplate(u.template, kind, apiVersion, u.config)
+		if err != nil {
+			return "", fmt.Errorf("Invalid schema location template %s: %s", u.baseURL, err)
+		}
+		return schemaRef, nil
+	}
+	return determineSchemaURL(u.baseURL, kind, apiVersion, u.config), nil
+}
+
+func (u *urlSchemaProvider) Schema(ctx context.Context, apiVersion, kind string) (*gojsonschema.Schema, error) {
+	schema, _, err := u.documentedSchema(ctx, apiVersion, kind)
+	return schema, err
+}
+
+func (u *urlSchemaProvider) documentedSchema(ctx context.Context, apiVersion, kind string) (*gojsonschema.Schema, interface{}, error) {
+	schemaRef, err := u.schemaRef(apiVersion, kind)
+	if err != nil {
+		return nil, nil, err
+	}
+	schemaLoader, err := u.schemaLoader(ctx, schemaRef)
+	var schema *gojsonschema.Schema
+	var document interface{}
+	if err == nil {
+		schema, document, err = compileLoadedSchema(schemaLoader)
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("Failed initializing schema %s: %w", schemaRef, err)
+	}
+	return schema, document, nil
+}
+
+func (u *urlSchemaProvider) schemaDocument(ctx context.Context, apiVersion, kind string) (interface{}, error) {
+	schemaRef, err := u.schemaRef(apiVersion, kind)
+	if err != nil {
+		return nil, err
+	}
+	schemaLoader, err := u.schemaLoader(ctx, schemaRef)
+	if err != nil {
+		return nil, err
+	}
+	return schemaLoader.LoadJSON()
+}
+
+// schemaLoader returns a loader for the schema at schemaRef, which is
+// read from the archive if the provider has one
+func (u *urlSchemaProvider) schemaLoader(ctx context.Context, schemaRef string) (gojsonschema.JSONLoader, error) {
+	if u.archive == "" {
+		return newSchemaLoader(ctx, schemaRef, u.config), nil
+	}
+	u.archiveOnce.Do(func() {
+		u.archiveFiles, u.archiveErr = readBundle(u.archive)
+	})
+	if u.archiveErr != nil {
+		return nil, u.archiveErr
+	}
+	name := strings.TrimPrefix(schemaRef, u.baseURL+"/")
+	body, ok := u.archiveFiles[name]
+	if !ok {
+		return nil, fmt.Errorf("%s is not in %s: %w", name, u.archive, os.ErrNotExist)
+	}
+	return quantityLoader{gojsonschema.NewBytesLoader(body)}, nil
+}
+
+func (u *urlSchemaProvider) schemaLocation(apiVersion, kind string) string {
+	schemaRef, _ := u.schemaRef(apiVersion, kind)
+	return schemaRef
+}
+
+// crdSchemaProvider builds schemas from CustomResourceDefinitions on disk,
+// which are read the first time a schema is needed
+type crdSchemaProvider struct {
+	locations []string
+	config    *Config
+
+	once        sync.Once
+	definitions *crdDefinitions
+	err         error
+}
+
+// NewCRDSchemaProvider returns a SchemaProvider which builds schemas from
+// the CustomResourceDefinitions found in the given files and directories
+func NewCRDSchemaProvider(locations []string, config *Config) SchemaProvider {
+	return &crdSchemaProvider{locations: locations, config: config}
+}
+
+// load reads the CustomResourceDefinitions the first time it's called
+func (c *crdSchemaProvider) load() {
+	c.once.Do(func() {
+		c.definitions, c.err = loadCRDSchemas(c.locations)
+	})
+}
+
+func (c *crdSchemaProvider) Schema(ctx context.Context, apiVersion, kind string) (*gojsonschema.Schema, error) {
+	c.load()
+	if c.err != nil {
+		return nil, fmt.Errorf("Failed loading CustomResourceDefinitions: %s", c.err)
+	}
+	key := versionKind(apiVersion, kind)
+	raw, ok := c.definitions.schemas[key]
+	if !ok && len(c.definitions.skipped) > 0 {
+		// The definition may be in one of the documents which were skipped
+		return nil, fmt.Errorf("No CustomResourceDefinition for %s in %v, skipping documents which couldn't be read: %s", key, c.locations, strings.Join(c.definitions.skipped, "; "))
+	} else if !ok {
+		return nil, fmt.Errorf("No CustomResourceDefinition for %s in %v", key, c.locations)
+	}
+	return compileSchema(key, raw, c.config)
+}
+
+func (c *crdSchemaProvider) schemaDocument(ctx context.Context, apiVersion, kind string) (interface{}, error) {
+	c.load()
+	if c.err != nil {
+		return nil, c.err
+	}
+	if raw, ok := c.definitions.schemas[versionKind(apiVersion, kind)]; ok {
+		return raw, nil
+	}
+	return nil, nil
+}
+
+func (c *crdSchemaProvider) schemaLocation(apiVersion, kind string) string {
+	c.load()
+	if c.err != nil {
+		return ""
+	}
+	return c.definitions.files[versionKind(apiVersion, kind)]
+}
+
+func (c *crdSchemaProvider) clusterScoped(apiVersion, kind string) (bool, bool) {
+	c.load()
+	if c.err != nil {
+		return false, false
+	}
+	clusterScoped, ok := c.definitions.scopes[apiVersionGroupKind(apiVersion, kind)]
+	return clusterScoped, ok
+}
+
+// openAPISchemaProvider builds schemas from a Kubernetes OpenAPI v2
+// document, which is read the first time a schema is needed
+type openAPISchemaProvider struct {
+	location string
+	config   *Config
+
+	once sync.Once
+	spec *openAPIDocument
+	err  error
+}
+
+// NewOpenAPISchemaProvider returns a SchemaProvider which builds schemas
+// from the Kubernetes OpenAPI v2 document (swagger.json) at location
+func NewOpenAPISchemaProvider(location string, config *Config) SchemaProvider {
+	return &openAPISchemaProvider{location: location, config: config}
+}
+
+// load reads the OpenAPI document the first time it's called
+func (o *openAPISchemaProvider) load() {
+	o.once.Do(func() {
+		// The document is read once for every caller, so it isn't
+		// abandoned when the first of them is cancelled
+		o.spec, o.err = loadOpenAPIV2Spec(context.Background(), o.location, o.config)
+	})
+}
+
+func (o *openAPISchemaProvider) Schema(ctx context.Context, apiVersion, kind string) (*gojsonschema.Schema, error) {
+	o.load()
+	if o.err != nil {
+		return nil, fmt.Errorf("Failed loading OpenAPI spec %s: %w", o.location, o.err)
+	}
+	key := versionKind(apiVersion, kind)
+	raw := o.spec.schema(apiVersion, kind)
+	if raw == nil {
+		return nil, fmt.Errorf("No schema for %s in OpenAPI spec %s", key, o.location)
+	}
+	return compileSchema(key, raw, o.config)
+}
+
+func (o *openAPISchemaProvider) schemaDocument(ctx context.Context, apiVersion, kind string) (interface{}, error) {
+	o.load()
+	if o.err != nil {
+		return nil, o.err
+	}
+	if raw := o.spec.schema(apiVersion, kind); raw != nil {
+		return raw, nil
+	}
+	return nil, nil
+}
+
+func (o *openAPISchemaProvider) schemaLocation(apiVersion, kind string) string {
+	o.load()
+	if o.err != nil {
+		return ""
+	}
+	return o.spec.location
+}
+
+// openAPIV3SchemaProvider builds schemas from Kubernetes OpenAPI v3
+// documents, reading the document for each group and version once
+type openAPIV3SchemaProvider struct {
+	location string
+	config   *Config
+
+	mu        sync.Mutex
+	documents map[string]*openAPIDocumentLoad
+}
+
+// openAPIDocumentLoad is a read of an OpenAPI v3 document which other
+// goroutines can wait on
+type openAPIDocumentLoad struct {
+	done     chan struct{}
+	document *openAPIDocument
+	err      error
+}
+
+// NewOpenAPIV3SchemaProvider returns a SchemaProvider which builds schemas
+// from the tree of Kubernetes OpenAPI v3 documents at location, laid out
+// as an API server serves them under /openapi/v3
+func NewOpenAPIV3SchemaProvider(location string, config *Config) SchemaProvider {
+	return &openAPIV3SchemaProvider{
+		location:  location,
+		config:    config,
+		documents: make(map[string]*openAPIDocumentLoad),
+	}
+}
+
+func (o *openAPIV3SchemaProvider) Schema(ctx context.Context, apiVersion, kind string) (*gojsonschema.Schema, error) {
+	document, err := o.document(ctx, apiVersion)
+	if err != nil {
+		return nil, fmt.Errorf("Failed loading OpenAPI v3 document: %w", err)
+	}
+	key := versionKind(apiVersion, kind)
+	if document != nil {
+		if raw := document.schema(apiVersion, kind); raw != nil {
+			return compileSchema(key, raw, o.config)
+		}
+	}
+	return nil, fmt.Errorf("No schema for %s in OpenAPI v3 documents at %s", key, o.location)
+}
+
+func (o *openAPIV3SchemaProvider) schemaDocument(ctx context.Context, apiVersion, kind string) (interface{}, error) {
+	document, err := o.document(ctx, apiVersion)
+	if err != nil || document == nil {
+		return nil, err
+	}
+	if raw := document.schema(apiVersion, kind); raw != nil {
+		return raw, nil
+	}
+	return nil, nil
+}
+
+func (o *openAPIV3SchemaProvider) schemaLocation(apiVersion, kind string) string {
+	// The document has already been read by the time its location is asked for
+	document, err := o.document(context.Background(), apiVersion)
+	if err != nil || document == nil {
+		return ""
+	}
+	return document.location
+}
+
+// document returns the OpenAPI v3 document for the group and version of
+// apiVersion, or nil if there is none. Only one goroutine reads each
+// document, while documents for other groups and versions are read at the
+// same time. A failed read isn't kept, so it's tried again next time,
+// including one which stopped because ctx was done.
+func (o *openAPIV3SchemaProvider) document(ctx context.Context, apiVersion string) (*openAPIDocument, error) {
+	o.mu.Lock()
+	if load, ok := o.documents[apiVersion]; ok {
+		o.mu.Unlock()
+		<-load.done
+		return load.document, load.err
+	}
+	load := &openAPIDocumentLoad{done: make(chan struct{})}
+	o.documents[apiVersion] = load
+	o.mu.Unlock()
+
+	load.document, load.err = loadOpenAPIV3Document(ctx, o.location, apiVersion, o.config)
+	if load.err != nil {
+		o.mu.Lock()
+		delete(o.documents, apiVersion)
+		o.mu.Unlock()
+	}
+	close(load.done)
+	return load.document, load.err
+}
@@ -0,0 +1,254 @@
+package kubeval
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/xeipuuv/gojsonschema"
+)
+
+// staticSchemaProvider returns the same schema for a single kind, and
+// counts how often it is asked for a schema. Its document is returned for
+// every kind.
+type staticSchemaProvider struct {
+	kind     string
+	schema   *gojsonschema.Schema
+	document interface{}
+	calls    int
+}
+
+func (s *staticSchemaProvider) Schema(ctx context.Context, apiVersion, kind string) (*gojsonschema.Schema, error) {
+	s.calls++
+	if kind != s.kind {
+		return nil, fmt.Errorf("No schema for %s", versionKind(apiVersion, kind))
+	}
+	return s.schema, nil
+}
+
+func (s *staticSchemaProvider) schemaDocument(ctx context.Context, apiVersion, kind string) (interface{}, error) {
+	return s.document, nil
+}
+
+func TestValidateWithSchemaProvider(t *testing.T) {
+	schema, err := gojsonschema.NewSchema(gojsonschema.NewStringLoader(`{
+		"type": "object",
+		"properties": {"spec": {"type": "object", "properties": {"replicas": {"type": "integer"}}}}
+	}`))
+	if err != nil {
+		t.Fatalf("Unexpected error compiling schema: %s", err.Error())
+	}
+	provider := &staticSchemaProvider{kind: "ReplicationController", schema: schema}
+
+	config := NewDefaultConfig()
+	config.SchemaProvider = provider
+	// make sure nothing is downloaded
+	config.SchemaLocation = "file:///nonexistent"
+	schemaCache := NewSchemaCache()
+	for _, fixture := range []string{"invalid.yaml", "invalid.yaml"} {
+		config.FileName = fixture
+		filePath, _ := filepath.Abs("../fixtures/" + fixture)
+		fileContents, _ := ioutil.ReadFile(filePath)
+		results, err := ValidateWithCache(fileContents, schemaCache, config)
+		if err != nil {
+			t.Fatalf("Unexpected error for %s: %s", fixture, err.Error())
+		}
+		if len(results[0].Errors) != 1 {
+			t.Errorf("%s should have 1 error from the provided schema, got: %v", fixture, results[0].Errors)
+		}
+	}
+	if provider.calls != 1 {
+		t.Errorf("Schema provider should be asked once for a cached schema, got %d calls", provider.calls)
+	}
+
+	config.FileName = "service.yaml"
+	_, err = Validate([]byte("apiVersion: v1\nkind: Service\nmetadata:\n  name: bob\n"), config)
+	if err == nil || !strings.Contains(err.Error(), "No schema for v1/Service") {
+		t.Errorf("Expected the provider's error for a missing schema, got: %v", err)
+	}
+}
+
+func TestChainSchemaProvider(t *testing.T) {
+	config := NewDefaultConfig()
+	schemaLocation, _ := filepath.Abs("../fixtures/schemas")
+	failing := &staticSchemaProvider{kind: "Deployment", document: map[string]interface{}{}}
+	chain := NewChainSchemaProvider(failing, NewURLSchemaProvider("file://"+schemaLocation, config))
+
+	schema, err := chain.Schema(context.Background(), "v1", "ReplicationController")
+	if err != nil || schema == nil {
+		t.Errorf("Expected a schema from the second provider, got: %v", err)
+	}
+	if failing.calls != 1 {
+		t.Errorf("Expected the first provider to be asked first, got %d calls", failing.calls)
+	}
+
+	// The document comes from the provider which found the schema
+	document, err := chain.(schemaDocumentProvider).schemaDocument(context.Background(), "v1", "ReplicationController")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	if raw, ok := document.(map[string]interface{}); !ok || raw["properties"] == nil {
+		t.Errorf("Expected the document of the schema from the second provider, got %v", document)
+	}
+
+	_, err = chain.Schema(context.Background(), "v1", "Service")
+	if err == nil {
+		t.Fatalf("Expected an error when no provider has a schema")
+	}
+	for _, expected := range []string{"No schema for v1/Service", "service-v1.json"} {
+		if !strings.Contains(err.Error(), expected) {
+			t.Errorf("Expected %q in the error from every provider, got: %s", expected, err.Error())
+		}
+	}
+}
+
+func TestExecuteSchemaURLTemplate(t *testing.T) {
+	var tests = []struct {
+		Template   string
+		APIVersion string
+		Kind       string
+		Version    string
+		Strict     bool
+		Expected   string
+	}{
+		{
+			Template:   "https://example.com/{{ .Group }}/{{ .Kind | lower }}_{{ .ResourceAPIVersion }}.json",
+			APIVersion: "monitoring.coreos.com/v1",
+			Kind:       "ServiceMonitor",
+			Version:    "master",
+			Expected:   "https://example.com/monitoring.coreos.com/servicemonitor_v1.json",
+		},
+		{
+			Template:   "https://example.com/{{ .NormalizedVersion }}-standalone{{ .StrictSuffix }}/{{ .Kind }}.json",
+			APIVersion: "v1",
+			Kind:       "Pod",
+			Version:    "1.18.0",
+			Strict:     true,
+			Expected:   "https://example.com/v1.18.0-standalone-strict/Pod.json",
+		},
+		{
+			Template:   "file:///schemas/{{ .KubernetesVersion }}/{{ or .Group \"core\" }}/{{ .Kind }}.json",
+			APIVersion: "v1",
+			Kind:       "Service",
+			Version:    "1.18.0",
+			Expected:   "file:///schemas/1.18.0/core/Service.json",
+		},
+	}
+	for _, test := range tests {
+		tmpl, err := parseSchemaURLTemplate(test.Template)
+		if err != nil {
+			t.Errorf("Unexpected error parsing %s: %s", test.Template, err.Error())
+			continue
+		}
+		config := &Config{KubernetesVersion: test.Version, Strict: test.Strict}
+		url, err := executeSchemaURLTemplate(tmpl, test.Kind, test.APIVersion, config)
+		if err != nil {
+			t.Errorf("Unexpected error executing %s: %s", test.Template, err.Error())
+		} else if url != test.Expected {
+			t.Errorf("Expected %s, got %s", test.Expected, url)
+		}
+	}
+}
+
+func TestValidateWithSchemaURLTemplate(t *testing.T) {
+	catalog, _ := filepath.Abs("../fixtures/schemas/catalog")
+	config := NewDefaultConfig()
+	config.FileName = "sealedsecret.yaml"
+	config.SchemaLocation = "file:///nonexistent"
+	config.AdditionalSchemaLocations = []string{"file://" + catalog + "/{{ .Group }}/{{ .Kind | lower }}_{{ .ResourceAPIVersion }}.json"}
+	fileContents, _ := ioutil.ReadFile("../fixtures/sealedsecret.yaml")
+	results, err := Validate(fileContents, config)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	if !results[0].ValidatedAgainstSchema || len(results[0].Errors) != 0 {
+		t.Errorf("Expected sealedsecret.yaml to be valid against the catalog schema, got: %v", results[0].Errors)
+	}
+
+	config.SchemaLocation = "file:///{{ .Kind"
+	config.AdditionalSchemaLocations = nil
+	_, err = Validate(fileContents, config)
+	if err == nil || !strings.Contains(err.Error(), "Invalid schema location template") {
+		t.Errorf("Expected an error for an invalid template, got: %v", err)
+	}
+}
+
+func TestSchemaLocation(t *testing.T) {
+	catalog, _ := filepath.Abs("../fixtures/schemas/catalog")
+	var tests = []struct {
+		Name      string
+		Fixture   string
+		Configure func(*Config)
+		Expected  string
+	}{
+		{
+			Name:    "schema location template",
+			Fixture: "sealedsecret.yaml",
+			Configure: func(config *Config) {
+				config.AdditionalSchemaLocations = []string{"file://" + catalog + "/{{ .Group }}/{{ .Kind | lower }}_{{ .ResourceAPIVersion }}.json"}
+			},
+			Expected: "file://" + catalog + "/bitnami.com/sealedsecret_v1alpha1.json",
+		},
+		{
+			Name:    "crd location",
+			Fixture: "test_crd.yaml",
+			Configure: func(config *Config) {
+				config.CRDLocations = []string{"../fixtures/crds"}
+			},
+			Expected: "../fixtures/crds/sealedsecret.yaml",
+		},
+		{
+			Name:    "crd in the input",
+			Fixture: "crd_with_resources.yaml",
+			Configure: func(config *Config) {
+				config.KindsToSkip = []string{"CustomResourceDefinition"}
+			},
+			Expected: "crd_with_resources.yaml",
+		},
+	}
+	for _, test := range tests {
+		config := NewDefaultConfig()
+		config.FileName = test.Fixture
+		config.SchemaLocation = "file:///nonexistent"
+		test.Configure(config)
+		fileContents, _ := ioutil.ReadFile("../fixtures/" + test.Fixture)
+		results, err := Validate(fileContents, config)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", test.Name, err.Error())
+			continue
+		}
+		result := results[len(results)-1]
+		if result.SchemaLocation != test.Expected {
+			t.Errorf("%s: expected the schema location %s, got %q", test.Name, test.Expected, result.SchemaLocation)
+		}
+	}
+}
+
+func TestCRDSchemaProviderSkipsUnreadableDocuments(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kubeval-crds")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	crd, _ := ioutil.ReadFile("../fixtures/crds/sealedsecret.yaml")
+	broken := "key: [\n---\napiVersion: apiextensions.k8s.io/v1\nkind: CustomResourceDefinition\nspec: {}\n---\n" + string(crd)
+	if err := ioutil.WriteFile(filepath.Join(dir, "crds.yaml"), []byte(broken), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	config := NewDefaultConfig()
+	provider := NewCRDSchemaProvider([]string{dir}, config)
+	if schema, err := provider.Schema(context.Background(), "bitnami.com/v1alpha1", "SealedSecret"); err != nil || schema == nil {
+		t.Errorf("Expected the schema from the readable CustomResourceDefinition, got %v", err)
+	}
+
+	// Only the first error in the file is reported, when a kind isn't found
+	_, err = provider.Schema(context.Background(), "example.com/v1", "Widget")
+	if err == nil || !strings.Contains(err.Error(), "Failed to decode YAML from "+filepath.Join(dir, "crds.yaml")) || strings.Count(err.Error(), "crds.yaml") != 1 {
+		t.Errorf("Expected the kind to be missing, mentioning the document which was skipped, got %v", err)
+	}
+}
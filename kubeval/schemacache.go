@@ -0,0 +1,240 @@
+package kubeval
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+
+	"github.com/xeipuuv/gojsonschema"
+)
+
+// SchemaCache holds the schemas found for each apiVersion and kind, and
+// can be shared between goroutines validating at the same time. A nil
+// schema is cached when no schema could be found, so that it isn't
+// searched for again.
+type SchemaCache struct {
+	mu       sync.Mutex
+	schemas  map[string]*gojsonschema.Schema
+	inflight map[string]*schemaLoad
+	hits     int
+	misses   int
+
+	// errors holds why no schema was found for each negative entry, so
+	// that every resource of that kind reports it, whichever is first
+	errors map[string]error
+
+	// scopes holds whether the kinds defined by CustomResourceDefinitions
+	// seen while validating are cluster-scoped, keyed by groupKind
+	scopes map[string]bool
+
+	// documents holds the JSON schema documents which schemas were
+	// compiled from, which are only found when they're needed to suggest
+	// fields. A nil document records that none could be found.
+	documents map[string]interface{}
+
+	// locations holds where each schema was read from
+	locations map[string]string
+}
+
+// schemaLoad is a search for a schema which other goroutines can wait on
+type schemaLoad struct {
+	done   chan struct{}
+	schema *gojsonschema.Schema
+	err    error
+}
+
+// SchemaCacheStats counts the use of a SchemaCache
+type SchemaCacheStats struct {
+	// Hits is the number of lookups answered from the cache, including
+	// those which waited on another goroutine finding the same schema
+	Hits int
+	// Misses is the number of lookups which had to search for a schema
+	Misses int
+	// Entries is the number of apiVersions and kinds cached
+	Entries int
+	// Negative is the number of entries for which no schema was found
+	Negative int
+}
+
+// NewSharedSchemaCache returns a new, empty SchemaCache to be used with
+// ValidateWithSchemaCache
+func NewSharedSchemaCache() *SchemaCache {
+	return newSchemaCacheFromMap(make(map[string]*gojsonschema.Schema))
+}
+
+// newSchemaCacheFromMap returns a SchemaCache which stores its schemas in
+// the given map, as used by ValidateWithCache
+func newSchemaCacheFromMap(schemas map[string]*gojsonschema.Schema) *SchemaCache {
+	return &SchemaCache{
+		schemas:   schemas,
+		inflight:  make(map[string]*schemaLoad),
+		errors:    make(map[string]error),
+		scopes:    make(map[string]bool),
+		documents: make(map[string]interface{}),
+		locations: make(map[string]string),
+	}
+}
+
+// Get returns the cached schema for the given apiVersion and kind, and
+// whether there is an entry for them. The schema is nil if no schema was
+// found for them.
+func (c *SchemaCache) Get(apiVersion, kind string) (*gojsonschema.Schema, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	schema, ok := c.schemas[versionKind(apiVersion, kind)]
+	if ok {
+		c.hits++
+	} else {
+		c.misses++
+	}
+	return schema, ok
+}
+
+// Put caches the schema for the given apiVersion and kind, replacing any
+// existing entry. A nil schema records that there is no schema for them.
+func (c *SchemaCache) Put(apiVersion, kind string, schema *gojsonschema.Schema) {
+	c.put(versionKind(apiVersion, kind), schema)
+}
+
+// put caches the schema under a key made by versionKind
+func (c *SchemaCache) put(key string, schema *gojsonschema.Schema) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.schemas[key] = schema
+	delete(c.errors, key)
+}
+
+// Stats returns the number of hits, misses and entries in the cache
+func (c *SchemaCache) Stats() SchemaCacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	stats := SchemaCacheStats{
+		Hits:    c.hits,
+		Misses:  c.misses,
+		Entries: len(c.schemas),
+	}
+	for _, schema := range c.schemas {
+		if schema == nil {
+			stats.Negative++
+		}
+	}
+	return stats
+}
+
+// getOrLoad returns the cached schema for the given apiVersion and kind,
+// or calls load to find it. Only one goroutine calls load for the same
+// apiVersion and kind at a time; any others wait for its result. The
+// result is cached as nil if load fails because there is no schema, and
+// its error is returned by later lookups. Transient failures aren't
+// cached, so that the schema is looked for again next time.
+func (c *SchemaCache) getOrLoad(apiVersion, kind string, load func() (*gojsonschema.Schema, error)) (*gojsonschema.Schema, error) {
+	key := versionKind(apiVersion, kind)
+
+	c.mu.Lock()
+	if schema, ok := c.schemas[key]; ok {
+		c.hits++
+		err := c.errors[key]
+		c.mu.Unlock()
+		return schema, err
+	}
+	if inflight, ok := c.inflight[key]; ok {
+		c.hits++
+		c.mu.Unlock()
+		<-inflight.done
+		return inflight.schema, inflight.err
+	}
+	c.misses++
+	inflight := &schemaLoad{done: make(chan struct{})}
+	c.inflight[key] = inflight
+	c.mu.Unlock()
+
+	inflight.schema, inflight.err = load()
+	if inflight.err != nil {
+		inflight.schema = nil
+	}
+
+	// A schema put in the cache while loading, such as one declared by a
+	// CustomResourceDefinition, takes the place of what was loaded
+	c.mu.Lock()
+	if schema, ok := c.schemas[key]; ok {
+		inflight.schema, inflight.err = schema, c.errors[key]
+	} else if !isTransientError(inflight.err) {
+		c.schemas[key] = inflight.schema
+		if inflight.err != nil {
+			c.errors[key] = inflight.err
+		}
+	}
+	delete(c.inflight, key)
+	c.mu.Unlock()
+	close(inflight.done)
+	return inflight.schema, inflight.err
+}
+
+// isTransientError returns whether an error finding a schema may not
+// happen again, such as the context being done, a network error or a
+// server failing to respond, rather than meaning there is no schema
+func isTransientError(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+	var statusErr *httpStatusError
+	return errors.As(err, &statusErr) && !statusErr.notFound()
+}
+
+// putScope records whether the kind of resource in the given API group,
+// as defined by a CustomResourceDefinition, is cluster-scoped
+func (c *SchemaCache) putScope(group, kind string, clusterScoped bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.scopes[groupKind(group, kind)] = clusterScoped
+}
+
+// scope returns whether resources of the given apiVersion and kind are
+// cluster-scoped, and whether a CustomResourceDefinition recorded it
+func (c *SchemaCache) scope(apiVersion, kind string) (bool, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	clusterScoped, ok := c.scopes[apiVersionGroupKind(apiVersion, kind)]
+	return clusterScoped, ok
+}
+
+// putDocument caches the document of a schema under a key made by
+// versionKind
+func (c *SchemaCache) putDocument(key string, document interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.documents[key] = document
+}
+
+// document returns the cached document of the schema for the given
+// apiVersion and kind, and whether there is an entry for them
+func (c *SchemaCache) document(apiVersion, kind string) (interface{}, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	document, ok := c.documents[versionKind(apiVersion, kind)]
+	return document, ok
+}
+
+// putLocation records where the schema cached under a key made by
+// versionKind was read from
+func (c *SchemaCache) putLocation(key string, location string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.locations[key] = location
+}
+
+// location returns where the cached schema for the given apiVersion and
+// kind was read from, or "" if it isn't known
+func (c *SchemaCache) location(apiVersion, kind string) string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.locations[versionKind(apiVersion, kind)]
+}
@@ -0,0 +1,237 @@
+package kubeval
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	multierror "github.com/hashicorp/go-multierror"
+	"github.com/xeipuuv/gojsonschema"
+)
+
+// slowSchemaProvider takes a while to find a schema, so that concurrent
+// lookups overlap, and counts how often it is asked for one
+type slowSchemaProvider struct {
+	schema *gojsonschema.Schema
+	calls  int32
+}
+
+func (s *slowSchemaProvider) Schema(ctx context.Context, apiVersion, kind string) (*gojsonschema.Schema, error) {
+	atomic.AddInt32(&s.calls, 1)
+	time.Sleep(50 * time.Millisecond)
+	if kind != "ReplicationController" {
+		return nil, fmt.Errorf("No schema for %s", versionKind(apiVersion, kind))
+	}
+	return s.schema, nil
+}
+
+func TestSchemaCacheSingleFlight(t *testing.T) {
+	schema, _ := gojsonschema.NewSchema(gojsonschema.NewStringLoader(`{"type": "object"}`))
+	provider := &slowSchemaProvider{schema: schema}
+	fileContents, _ := ioutil.ReadFile("../fixtures/valid.yaml")
+	schemaCache := NewSharedSchemaCache()
+
+	const goroutines = 10
+	var wg sync.WaitGroup
+	for i := 0; i < goroutines; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			config := NewDefaultConfig()
+			config.SchemaProvider = provider
+			results, err := ValidateWithSchemaCache(fileContents, schemaCache, config)
+			if err != nil {
+				t.Errorf("Unexpected error: %s", err.Error())
+			} else if !results[0].ValidatedAgainstSchema {
+				t.Errorf("Expected valid.yaml to be validated against the provided schema")
+			}
+		}()
+	}
+	wg.Wait()
+
+	if calls := atomic.LoadInt32(&provider.calls); calls != 1 {
+		t.Errorf("Expected the schema to be found once, got %d calls", calls)
+	}
+	stats := schemaCache.Stats()
+	if stats.Misses != 1 || stats.Hits != goroutines-1 || stats.Entries != 1 || stats.Negative != 0 {
+		t.Errorf("Unexpected cache stats: %+v", stats)
+	}
+
+	config := NewDefaultConfig()
+	config.SchemaProvider = provider
+	_, err := ValidateWithSchemaCache([]byte("apiVersion: v1\nkind: Service\nmetadata:\n  name: bob\n"), schemaCache, config)
+	if err == nil {
+		t.Errorf("Expected an error for a missing schema")
+	}
+	if stats := schemaCache.Stats(); stats.Entries != 2 || stats.Negative != 1 {
+		t.Errorf("Expected a negative entry for the missing schema, got: %+v", stats)
+	}
+	if schema, ok := schemaCache.Get("v1", "Service"); !ok || schema != nil {
+		t.Errorf("Expected a cached nil schema for v1/Service")
+	}
+}
+
+func TestSchemaCacheTransientErrors(t *testing.T) {
+	var missing *multierror.Error
+	missing = multierror.Append(missing, fmt.Errorf("No schema for v1/Pod"), newHTTPStatusError("404 Not Found"))
+	var failing *multierror.Error
+	failing = multierror.Append(failing, fmt.Errorf("No schema for v1/Pod"), fmt.Errorf("Failed initializing schema: %w", newHTTPStatusError("503 Service Unavailable")))
+
+	var tests = []struct {
+		Name   string
+		Err    error
+		Cached bool
+	}{
+		{"not found", missing, true},
+		{"gone", newHTTPStatusError("410 Gone"), true},
+		{"server error", failing, false},
+		{"too many requests", newHTTPStatusError("429 Too Many Requests"), false},
+		{"canceled", fmt.Errorf("%w: No schema for v1/Pod", context.Canceled), false},
+		{"deadline exceeded", context.DeadlineExceeded, false},
+		{"network error", fmt.Errorf("Failed initializing schema: %w", &net.DNSError{Err: "no such host", Name: "example.com"}), false},
+	}
+	for _, test := range tests {
+		schemaCache := NewSharedSchemaCache()
+		calls := 0
+		load := func() (*gojsonschema.Schema, error) {
+			calls++
+			return nil, test.Err
+		}
+		for i := 0; i < 2; i++ {
+			if _, err := schemaCache.getOrLoad("v1", "Pod", load); err == nil {
+				t.Errorf("%s: expected an error", test.Name)
+			}
+		}
+		expectedCalls := 2
+		if test.Cached {
+			expectedCalls = 1
+		}
+		if calls != expectedCalls {
+			t.Errorf("%s: expected %d calls to load, got %d", test.Name, expectedCalls, calls)
+		}
+		if _, ok := schemaCache.Get("v1", "Pod"); ok != test.Cached {
+			t.Errorf("%s: expected a cache entry to be %t, got %t", test.Name, test.Cached, ok)
+		}
+	}
+}
+
+func TestSchemaCacheGetPut(t *testing.T) {
+	schemaCache := NewSharedSchemaCache()
+	if _, ok := schemaCache.Get("v1", "Pod"); ok {
+		t.Errorf("Expected no entry in an empty cache")
+	}
+	schema, _ := gojsonschema.NewSchema(gojsonschema.NewStringLoader(`{"type": "object"}`))
+	schemaCache.Put("v1", "Pod", schema)
+	if cached, ok := schemaCache.Get("v1", "Pod"); !ok || cached != schema {
+		t.Errorf("Expected the schema which was put in the cache")
+	}
+	if stats := schemaCache.Stats(); stats.Hits != 1 || stats.Misses != 1 {
+		t.Errorf("Unexpected cache stats: %+v", stats)
+	}
+}
+
+func TestSchemaCachePutWhileLoading(t *testing.T) {
+	schemaCache := NewSharedSchemaCache()
+	schema, _ := gojsonschema.NewSchema(gojsonschema.NewStringLoader(`{"type": "object"}`))
+	loading := make(chan struct{})
+	put := make(chan struct{})
+	go func() {
+		<-loading
+		schemaCache.Put("example.com/v1", "Widget", schema)
+		close(put)
+	}()
+
+	// The schema put while the search fails takes the place of its result
+	loaded, err := schemaCache.getOrLoad("example.com/v1", "Widget", func() (*gojsonschema.Schema, error) {
+		close(loading)
+		<-put
+		return nil, newHTTPStatusError("404 Not Found")
+	})
+	if err != nil || loaded != schema {
+		t.Errorf("Expected the schema which was put in the cache, got %v and %v", loaded, err)
+	}
+	if cached, ok := schemaCache.Get("example.com/v1", "Widget"); !ok || cached != schema {
+		t.Errorf("Expected the cache to keep the schema which was put in it")
+	}
+}
+
+func TestValidateWithConcurrency(t *testing.T) {
+	schema, _ := gojsonschema.NewSchema(gojsonschema.NewStringLoader(`{"type": "object"}`))
+	provider := &slowSchemaProvider{schema: schema}
+	input := []byte(`apiVersion: v1
+kind: ReplicationController
+metadata:
+  name: first
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: first
+---
+apiVersion: v1
+kind: ReplicationController
+metadata:
+  name: second
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: second
+`)
+	config := NewDefaultConfig()
+	config.SchemaProvider = provider
+	config.Concurrency = 4
+	results, err := ValidateWithSchemaCache(input, NewSharedSchemaCache(), config)
+
+	if calls := atomic.LoadInt32(&provider.calls); calls != 2 {
+		t.Errorf("Expected one search per kind, got %d calls", calls)
+	}
+	if err == nil || strings.Count(err.Error(), "No schema for v1/Service") != 2 {
+		t.Errorf("Expected every Service to report the missing schema, got: %v", err)
+	}
+	var names []string
+	for _, result := range results {
+		names = append(names, result.Kind+"/"+result.ResourceName)
+	}
+	expected := []string{"ReplicationController/first", "Service/first", "ReplicationController/second", "Service/second"}
+	if strings.Join(names, ",") != strings.Join(expected, ",") {
+		t.Errorf("Expected results %v in document order, got %v", expected, names)
+	}
+}
+
+func TestSchemaCacheRetriesServerErrors(t *testing.T) {
+	var requests int32
+	files := http.FileServer(http.Dir("../fixtures/schemas"))
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		files.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	fileContents, _ := ioutil.ReadFile("../fixtures/valid.yaml")
+	config := NewDefaultConfig()
+	config.SchemaLocation = server.URL
+	config.NoCache = true
+	schemaCache := NewSharedSchemaCache()
+	if _, err := ValidateWithSchemaCache(fileContents, schemaCache, config); err == nil {
+		t.Errorf("Expected an error while the server is failing")
+	}
+	results, err := ValidateWithSchemaCache(fileContents, schemaCache, config)
+	if err != nil {
+		t.Fatalf("Unexpected error once the server recovered: %s", err.Error())
+	}
+	if !results[0].ValidatedAgainstSchema {
+		t.Errorf("Expected valid.yaml to be validated against the schema once the server recovered")
+	}
+}
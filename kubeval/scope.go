@@ -0,0 +1,72 @@
+package kubeval
+
+// clusterScopedKinds are the built-in kinds of resource which don't belong
+// to a namespace, keyed by groupKind
+var clusterScopedKinds = map[string]bool{
+	groupKind("", "ComponentStatus"):  true,
+	groupKind("", "Namespace"):        true,
+	groupKind("", "Node"):             true,
+	groupKind("", "PersistentVolume"): true,
+	groupKind("admissionregistration.k8s.io", "MutatingWebhookConfiguration"):     true,
+	groupKind("admissionregistration.k8s.io", "ValidatingAdmissionPolicy"):        true,
+	groupKind("admissionregistration.k8s.io", "ValidatingAdmissionPolicyBinding"): true,
+	groupKind("admissionregistration.k8s.io", "ValidatingWebhookConfiguration"):   true,
+	groupKind("apiextensions.k8s.io", "CustomResourceDefinition"):                 true,
+	groupKind("apiregistration.k8s.io", "APIService"):                             true,
+	groupKind("authentication.k8s.io", "SelfSubjectReview"):                       true,
+	groupKind("authentication.k8s.io", "TokenReview"):                             true,
+	groupKind("authorization.k8s.io", "SelfSubjectAccessReview"):                  true,
+	groupKind("authorization.k8s.io", "SelfSubjectRulesReview"):                   true,
+	groupKind("authorization.k8s.io", "SubjectAccessReview"):                      true,
+	groupKind("certificates.k8s.io", "CertificateSigningRequest"):                 true,
+	groupKind("flowcontrol.apiserver.k8s.io", "FlowSchema"):                       true,
+	groupKind("flowcontrol.apiserver.k8s.io", "PriorityLevelConfiguration"):       true,
+	groupKind("networking.k8s.io", "IngressClass"):                                true,
+	groupKind("node.k8s.io", "RuntimeClass"):                                      true,
+	groupKind("policy", "PodSecurityPolicy"):                                      true,
+	groupKind("rbac.authorization.k8s.io", "ClusterRole"):                         true,
+	groupKind("rbac.authorization.k8s.io", "ClusterRoleBinding"):                  true,
+	groupKind("scheduling.k8s.io", "PriorityClass"):                               true,
+	groupKind("storage.k8s.io", "CSIDriver"):                                      true,
+	groupKind("storage.k8s.io", "CSINode"):                                        true,
+	groupKind("storage.k8s.io", "StorageClass"):                                   true,
+	groupKind("storage.k8s.io", "VolumeAttachment"):                               true,
+}
+
+// groupKind returns the key used to identify the scope of a kind of
+// resource, which is the same for every version of its API group
+func groupKind(group, kind string) string {
+	return group + "/" + kind
+}
+
+// apiVersionGroupKind returns the groupKind for resources of the given
+// apiVersion and kind
+func apiVersionGroupKind(apiVersion, kind string) string {
+	group, _ := splitAPIVersion(apiVersion)
+	return groupKind(group, kind)
+}
+
+// scopeProvider is implemented by schema providers which also know the
+// scope of the resources they have schemas for, such as those defined by
+// CustomResourceDefinitions
+type scopeProvider interface {
+	// clusterScoped returns whether resources of the given apiVersion and
+	// kind are cluster-scoped, and whether their scope is known at all
+	clusterScoped(apiVersion, kind string) (bool, bool)
+}
+
+// isClusterScoped returns whether resources of the given apiVersion and
+// kind don't belong to a namespace. CustomResourceDefinitions earlier in
+// the input are consulted first, then the schema provider, and then the
+// built-in kinds. Anything unknown is assumed to be namespaced.
+func isClusterScoped(apiVersion, kind string, schemaCache *SchemaCache, provider SchemaProvider) bool {
+	if clusterScoped, ok := schemaCache.scope(apiVersion, kind); ok {
+		return clusterScoped
+	}
+	if scopes, ok := provider.(scopeProvider); ok {
+		if clusterScoped, ok := scopes.clusterScoped(apiVersion, kind); ok {
+			return clusterScoped
+		}
+	}
+	return clusterScopedKinds[apiVersionGroupKind(apiVersion, kind)]
+}
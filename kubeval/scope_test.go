@@ -0,0 +1,111 @@
+package kubeval
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// clusterCRD defines a cluster-scoped custom resource
+const clusterCRD = `apiVersion: apiextensions.k8s.io/v1
+kind: CustomResourceDefinition
+metadata:
+  name: clusterwidgets.example.com
+spec:
+  group: example.com
+  names:
+    kind: ClusterWidget
+    plural: clusterwidgets
+  scope: Cluster
+  versions:
+  - name: v1
+    served: true
+    storage: true
+    schema:
+      openAPIV3Schema:
+        type: object
+`
+
+func TestClusterScopedDuplicates(t *testing.T) {
+	input := []byte(`apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRole
+metadata:
+  name: admin
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRole
+metadata:
+  name: admin
+  namespace: default
+`)
+	config := NewDefaultConfig()
+	config.FileName = "roles.yaml"
+	config.IgnoreMissingSchemas = true
+
+	results, err := Validate(input, config)
+	expected := "roles.yaml: Duplicate cluster-scoped 'ClusterRole' resource 'admin' at roles.yaml:6, first defined at roles.yaml:1"
+	if err == nil || err.Error() != expected {
+		t.Errorf("Expected %q, got %v", expected, err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("Expected 2 results, got %d", len(results))
+	}
+	for _, result := range results {
+		if !result.ClusterScoped || result.ResourceNamespace != "" {
+			t.Errorf("Expected a cluster-scoped ClusterRole without a namespace, got %v and %q", result.ClusterScoped, result.ResourceNamespace)
+		}
+	}
+	if len(results[0].Warnings) != 0 {
+		t.Errorf("Expected no warnings for the ClusterRole without a namespace, got %v", results[0].Warnings)
+	}
+	if len(results[1].Warnings) != 1 || !strings.Contains(results[1].Warnings[0], "metadata.namespace 'default'") {
+		t.Errorf("Expected a warning about the namespace, got %v", results[1].Warnings)
+	}
+}
+
+func TestCRDScopes(t *testing.T) {
+	widgets := `---
+apiVersion: example.com/v1
+kind: ClusterWidget
+metadata:
+  name: widget
+  namespace: a
+---
+apiVersion: example.com/v1
+kind: ClusterWidget
+metadata:
+  name: widget
+  namespace: b
+`
+	config := NewDefaultConfig()
+	config.IgnoreMissingSchemas = true
+
+	// The scope of a CustomResourceDefinition earlier in the input
+	_, err := Validate([]byte(clusterCRD+widgets), config)
+	if err == nil || !strings.Contains(err.Error(), "Duplicate cluster-scoped 'ClusterWidget' resource 'widget'") {
+		t.Errorf("Expected the widgets to be duplicates, got %v", err)
+	}
+
+	// The scope of a CustomResourceDefinition in the CRD locations
+	dir, err := ioutil.TempDir("", "kubeval-crds")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := ioutil.WriteFile(filepath.Join(dir, "crd.yaml"), []byte(clusterCRD), 0644); err != nil {
+		t.Fatal(err)
+	}
+	config.CRDLocations = []string{dir}
+	_, err = Validate([]byte(widgets), config)
+	if err == nil || !strings.Contains(err.Error(), "Duplicate cluster-scoped 'ClusterWidget' resource 'widget'") {
+		t.Errorf("Expected the widgets to be duplicates, got %v", err)
+	}
+
+	// Without the CustomResourceDefinition, they're assumed to be namespaced
+	config.CRDLocations = nil
+	if _, err = Validate([]byte(widgets), config); err != nil {
+		t.Errorf("Unexpected error for namespaced widgets: %s", err.Error())
+	}
+}
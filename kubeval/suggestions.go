@@ -0,0 +1,279 @@
+package kubeval
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/xeipuuv/gojsonschema"
+)
+
+// schemaDocumentProvider is implemented by schema providers which can also
+// return the JSON schema document a schema was compiled from, so that the
+// properties it allows can be suggested in place of unknown ones
+type schemaDocumentProvider interface {
+	// schemaDocument returns the document of the schema for resources of
+	// the given apiVersion and kind, or nil if there is none
+	schemaDocument(ctx context.Context, apiVersion, kind string) (interface{}, error)
+}
+
+// documentedSchemaProvider is implemented by schema providers which read
+// the document a schema is compiled from, so that it can be cached along
+// with the schema rather than read again for suggestions
+type documentedSchemaProvider interface {
+	// documentedSchema returns the schema for resources of the given
+	// apiVersion and kind, and the document it was compiled from
+	documentedSchema(ctx context.Context, apiVersion, kind string) (*gojsonschema.Schema, interface{}, error)
+}
+
+// maxSchemaRefs limits how many $refs are followed in a row, in case they
+// form a cycle
+const maxSchemaRefs = 32
+
+// findSchemaDocument returns the document of the schema for resources of
+// the given apiVersion and kind, from the schema cache or else the
+// provider, or nil if neither has it
+func findSchemaDocument(ctx context.Context, apiVersion, kind string, schemaCache *SchemaCache, provider SchemaProvider) interface{} {
+	if document, ok := schemaCache.document(apiVersion, kind); ok {
+		return document
+	}
+	var document interface{}
+	if documents, ok := provider.(schemaDocumentProvider); ok {
+		document, _ = documents.schemaDocument(ctx, apiVersion, kind)
+	}
+	schemaCache.putDocument(versionKind(apiVersion, kind), document)
+	return document
+}
+
+// hasErrorType returns whether any of errors is of the given type
+func hasErrorType(errors []gojsonschema.ResultError, errorType string) bool {
+	for _, err := range errors {
+		if err.Type() == errorType {
+			return true
+		}
+	}
+	return false
+}
+
+// suggestFields sets the Suggestion of each of a result's FieldErrors
+// which is about an unknown field, using the properties which the schema
+// document allows
+func suggestFields(result *ValidationResult, document interface{}) {
+	if document == nil {
+		return
+	}
+	for i, err := range result.Errors {
+		if err.Type() != "additional_property_not_allowed" || i >= len(result.FieldErrors) {
+			continue
+		}
+		property, ok := err.Details()["property"].(string)
+		if !ok {
+			continue
+		}
+		if suggestion := suggestField(document, errorPath(err), property); suggestion != nil {
+			result.FieldErrors[i].Suggestion = formatFieldPath(suggestion)
+		}
+	}
+}
+
+// suggestField returns the path to the field which was probably meant by
+// an unknown property of the object at path: a property of the same
+// object with a similar name, or else one of the object which holds it,
+// for a field written one level too low, or else one of the objects it
+// holds, for a field written one level too high. It returns nil if there
+// is no such field.
+func suggestField(document interface{}, path []string, property string) []string {
+	node := schemaAt(document, document, path)
+	if node == nil {
+		return nil
+	}
+	if name := closestName(property, propertyNames(document, node)); name != "" {
+		return appendPath(path, name)
+	}
+
+	if parentPath, ok := parentObjectPath(path); ok {
+		parent := schemaAt(document, document, parentPath)
+		if name := closestName(property, propertyNames(document, parent)); name != "" {
+			return appendPath(parentPath, name)
+		}
+	}
+
+	for _, child := range propertyNames(document, node) {
+		childNode := childSchema(document, node, child)
+		if name := closestName(property, propertyNames(document, childNode)); name != "" {
+			return appendPath(appendPath(path, child), name)
+		}
+	}
+	return nil
+}
+
+// parentObjectPath returns the path to the object which holds the object
+// at path, skipping over the array it is an item of, if any
+func parentObjectPath(path []string) ([]string, bool) {
+	if len(path) == 0 {
+		return nil, false
+	}
+	parent := path[:len(path)-1]
+	if _, err := strconv.Atoi(path[len(path)-1]); err == nil && len(parent) > 0 {
+		parent = parent[:len(parent)-1]
+	}
+	return parent, true
+}
+
+// schemaAt follows path from node to the schema of the value at its end,
+// returning nil if the schema doesn't describe it
+func schemaAt(document, node interface{}, path []string) interface{} {
+	for _, field := range path {
+		node = childSchema(document, node, field)
+		if node == nil {
+			return nil
+		}
+	}
+	return node
+}
+
+// childSchema returns the schema of a field, or item of an array, of the
+// value which node is the schema of
+func childSchema(document, node interface{}, field string) interface{} {
+	schema := resolveSchemaRef(document, node)
+	if schema == nil {
+		return nil
+	}
+	if properties, ok := schema["properties"].(map[string]interface{}); ok {
+		if child, ok := properties[field]; ok {
+			return child
+		}
+	}
+	if _, err := strconv.Atoi(field); err == nil {
+		if items, ok := schema["items"].(map[string]interface{}); ok {
+			return items
+		}
+	}
+	for _, key := range []string{"allOf", "anyOf", "oneOf"} {
+		branches, _ := schema[key].([]interface{})
+		for _, branch := range branches {
+			if child := childSchema(document, branch, field); child != nil {
+				return child
+			}
+		}
+	}
+	if additional, ok := schema["additionalProperties"].(map[string]interface{}); ok {
+		return additional
+	}
+	return nil
+}
+
+// propertyNames returns the sorted names of the properties which node
+// declares, including those of its allOf, anyOf and oneOf schemas
+func propertyNames(document, node interface{}) []string {
+	seen := make(map[string]bool)
+	var collect func(node interface{})
+	collect = func(node interface{}) {
+		schema := resolveSchemaRef(document, node)
+		if schema == nil {
+			return
+		}
+		if properties, ok := schema["properties"].(map[string]interface{}); ok {
+			for name := range properties {
+				seen[name] = true
+			}
+		}
+		for _, key := range []string{"allOf", "anyOf", "oneOf"} {
+			branches, _ := schema[key].([]interface{})
+			for _, branch := range branches {
+				collect(branch)
+			}
+		}
+	}
+	collect(node)
+
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// resolveSchemaRef returns the schema node refers to within document, if
+// it's a $ref to a JSON pointer such as #/definitions/io.k8s.api.core.v1.Pod,
+// or node itself otherwise. References to other documents aren't followed.
+func resolveSchemaRef(document, node interface{}) map[string]interface{} {
+	schema, _ := node.(map[string]interface{})
+	for i := 0; i < maxSchemaRefs && schema != nil; i++ {
+		ref, ok := schema["$ref"].(string)
+		if !ok {
+			return schema
+		}
+		if !strings.HasPrefix(ref, "#/") {
+			return nil
+		}
+		var target interface{} = document
+		for _, token := range strings.Split(strings.TrimPrefix(ref, "#/"), "/") {
+			token = strings.Replace(strings.Replace(token, "~1", "/", -1), "~0", "~", -1)
+			object, _ := target.(map[string]interface{})
+			target = object[token]
+		}
+		schema, _ = target.(map[string]interface{})
+	}
+	return nil
+}
+
+// closestName returns the one of names which was most likely meant by
+// name: one which differs only in case, or else the nearest by edit
+// distance if that's close enough to be a typo. It returns "" if there's
+// no such name.
+func closestName(name string, names []string) string {
+	lower := strings.ToLower(name)
+	limit := len(name) / 3
+	if limit < 1 {
+		limit = 1
+	}
+	if limit > 3 {
+		limit = 3
+	}
+
+	closest, closestDistance := "", limit+1
+	for _, candidate := range names {
+		if strings.ToLower(candidate) == lower {
+			return candidate
+		}
+		if distance := editDistance(lower, strings.ToLower(candidate)); distance < closestDistance {
+			closest, closestDistance = candidate, distance
+		}
+	}
+	return closest
+}
+
+// editDistance returns the Levenshtein distance between a and b
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	previous := make([]int, len(rb)+1)
+	current := make([]int, len(rb)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		current[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			current[j] = min3(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(rb)]
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
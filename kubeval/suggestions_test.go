@@ -0,0 +1,114 @@
+package kubeval
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const suggestionSchema = `{
+	"definitions": {
+		"container": {
+			"properties": {
+				"image": {"type": "string"},
+				"imagePullPolicy": {"type": "string"}
+			}
+		}
+	},
+	"properties": {
+		"spec": {
+			"properties": {
+				"replicas": {"type": "integer"},
+				"template": {
+					"properties": {
+						"spec": {
+							"properties": {
+								"containers": {"items": {"$ref": "#/definitions/container"}},
+								"hostNetwork": {"type": "boolean"}
+							}
+						}
+					}
+				}
+			}
+		}
+	}
+}`
+
+func TestSuggestField(t *testing.T) {
+	var document interface{}
+	if err := json.Unmarshal([]byte(suggestionSchema), &document); err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	var tests = []struct {
+		Name     string
+		Path     []string
+		Property string
+		Expected string
+	}{
+		{"typo", []string{"spec", "template", "spec", "containers", "0"}, "imagePullPolcy", "spec.template.spec.containers[0].imagePullPolicy"},
+		{"case", []string{"spec"}, "Replicas", "spec.replicas"},
+		{"one level too low", []string{"spec", "template"}, "replicas", "spec.replicas"},
+		{"one level too low in an array", []string{"spec", "template", "spec", "containers", "0"}, "hostNetwork", "spec.template.spec.hostNetwork"},
+		{"one level too high", []string{"spec", "template"}, "hostNetwork", "spec.template.spec.hostNetwork"},
+		{"unrelated", []string{"spec"}, "selector", ""},
+		{"unknown path", []string{"status"}, "replicas", ""},
+	}
+	for _, test := range tests {
+		assert.Equal(t, test.Expected, formatFieldPath(suggestField(document, test.Path, test.Property)), test.Name)
+	}
+}
+
+func TestClosestName(t *testing.T) {
+	names := []string{"image", "imagePullPolicy", "name", "ports"}
+	assert.Equal(t, "imagePullPolicy", closestName("imagepullpolicy", names))
+	assert.Equal(t, "name", closestName("nme", names))
+	assert.Equal(t, "ports", closestName("port", names))
+	assert.Equal(t, "", closestName("command", names))
+}
+
+func TestValidateSuggestions(t *testing.T) {
+	input := []byte(`apiVersion: v1
+kind: ReplicationController
+metadata:
+  name: typo
+spec:
+  replica: 2
+`)
+	schemaLocation, _ := filepath.Abs("../fixtures/schemas")
+	config := NewDefaultConfig()
+	config.Strict = true
+	config.SchemaLocation = "file://" + schemaLocation
+	results, err := Validate(input, config)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	if len(results[0].FieldErrors) != 1 {
+		t.Fatalf("Expected one error, got %v", results[0].FieldErrors)
+	}
+	assert.Equal(t, "spec.replicas", results[0].FieldErrors[0].Suggestion)
+	assert.Equal(t, "spec.replica: Forbidden: unknown field; did you mean spec.replicas?", results[0].FieldErrors[0].String())
+}
+
+func TestSuggestionsReuseDownloadedSchema(t *testing.T) {
+	var requests int32
+	server := newSchemaServer(&requests)
+	defer server.Close()
+
+	config := NewDefaultConfig()
+	config.Strict = true
+	config.NoCache = true
+	config.SchemaLocation = server.URL
+	results, err := Validate([]byte("apiVersion: v1\nkind: ReplicationController\nmetadata:\n  name: typo\nspec:\n  replica: 2\n"), config)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	if len(results[0].FieldErrors) != 1 || results[0].FieldErrors[0].Suggestion != "spec.replicas" {
+		t.Errorf("Expected a suggestion for spec.replica, got %v", results[0].FieldErrors)
+	}
+	// The document the schema was compiled from is kept for suggestions
+	if requests != 1 {
+		t.Errorf("Expected the schema to be downloaded once, got %d downloads", requests)
+	}
+}
@@ -1,9 +1,7 @@
 package kubeval
 
 import (
-	"bytes"
 	"fmt"
-	"runtime"
 	"strings"
 )
 
@@ -63,13 +61,15 @@ func getString(body map[string]interface{}, key string) (string, error) {
 	return typedValue, nil
 }
 
-// detectLineBreak returns the relevant platform specific line ending
-func detectLineBreak(haystack []byte) string {
-	windowsLineEnding := bytes.Contains(haystack, []byte("\r\n"))
-	if windowsLineEnding && runtime.GOOS == "windows" {
-		return "\r\n"
+// hasManifestExtension returns whether the path looks like a
+// YAML or JSON manifest
+func hasManifestExtension(path string) bool {
+	for _, ext := range []string{".yaml", ".yml", ".json"} {
+		if strings.HasSuffix(path, ext) {
+			return true
+		}
 	}
-	return "\n"
+	return false
 }
 
 // in is a method which tests whether the `key` is in the set
@@ -0,0 +1,110 @@
+package kubeval
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/xeipuuv/gojsonschema"
+	yamlv3 "gopkg.in/yaml.v3"
+)
+
+// yamlBooleans are the plain scalars which YAML 1.1 decoders, including
+// the one used by kubectl and the API server, read as booleans, while YAML
+// 1.2 reads them as strings
+var yamlBooleans = map[string]bool{
+	"y": true, "Y": true, "yes": true, "Yes": true, "YES": true,
+	"n": true, "N": true, "no": true, "No": true, "NO": true,
+	"on": true, "On": true, "ON": true,
+	"off": true, "Off": true, "OFF": true,
+}
+
+// yamlFinding is a construct found by checkYAML, and where it is
+type yamlFinding struct {
+	err      gojsonschema.ResultError
+	position Position
+}
+
+// yamlChecker looks through the node tree of a document for duplicate
+// keys, and constructs which YAML decoders don't agree on
+type yamlChecker struct {
+	documentLine int
+	findings     []yamlFinding
+}
+
+// checkYAML returns a finding for each duplicate key, YAML 1.1 boolean
+// written as a string, merge key and alias in the tree under root. The
+// items of a List are left out when skipItems is set, as they are checked
+// along with the resource each of them holds.
+func checkYAML(root *yamlv3.Node, documentLine int, skipItems bool) []yamlFinding {
+	if root == nil {
+		return nil
+	}
+	checker := &yamlChecker{documentLine: documentLine}
+	context := gojsonschema.NewJsonContext(gojsonschema.STRING_CONTEXT_ROOT, nil)
+	if skipItems && root.Kind == yamlv3.MappingNode {
+		checker.checkMapping(root, context, "items")
+	} else {
+		checker.check(root, context)
+	}
+	return checker.findings
+}
+
+func (c *yamlChecker) check(node *yamlv3.Node, context *gojsonschema.JsonContext) {
+	switch node.Kind {
+	case yamlv3.MappingNode:
+		c.checkMapping(node, context, "")
+	case yamlv3.SequenceNode:
+		for i, item := range node.Content {
+			c.check(item, gojsonschema.NewJsonContext(strconv.Itoa(i), context))
+		}
+	case yamlv3.ScalarNode:
+		c.checkScalar(node, context)
+	case yamlv3.AliasNode:
+		// The anchored value is checked where it's defined
+		c.add(node, context, "alias", fmt.Sprintf("Alias *%s is expanded to a copy of the value at line %d, so the anchor isn't kept once applied", node.Value, c.line(node.Alias)))
+	}
+}
+
+// checkMapping checks the keys and values of a mapping, leaving out the
+// value of the key named skip, if any
+func (c *yamlChecker) checkMapping(node *yamlv3.Node, context *gojsonschema.JsonContext, skip string) {
+	seen := make(map[string]*yamlv3.Node)
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		if key.Tag == "!!merge" {
+			c.add(key, context, "merge_key", "Merge key is only part of YAML 1.1, so tools may not merge the same values")
+			c.check(value, context)
+			continue
+		}
+
+		field := gojsonschema.NewJsonContext(key.Value, context)
+		if previous, ok := seen[key.Value]; ok {
+			c.add(key, field, "duplicate_key", fmt.Sprintf("Duplicate key %s replaces the value at line %d", key.Value, c.line(previous)))
+		}
+		seen[key.Value] = key
+		c.checkScalar(key, field)
+		if key.Value != skip {
+			c.check(value, field)
+		}
+	}
+}
+
+func (c *yamlChecker) checkScalar(node *yamlv3.Node, context *gojsonschema.JsonContext) {
+	if node.Kind == yamlv3.ScalarNode && node.Style == 0 && node.Tag == "!!str" && yamlBooleans[node.Value] {
+		c.add(node, context, "yaml_boolean", fmt.Sprintf("%s is a boolean in YAML 1.1 but a string in YAML 1.2; quote it, or use true or false", node.Value))
+	}
+}
+
+// line returns the line of the input a node is on
+func (c *yamlChecker) line(node *yamlv3.Node) int {
+	return nodePosition(node, c.documentLine).Line
+}
+
+func (c *yamlChecker) add(node *yamlv3.Node, context *gojsonschema.JsonContext, errorType string, description string) {
+	err := &gojsonschema.ResultErrorFields{}
+	err.SetType(errorType)
+	err.SetContext(context)
+	err.SetDescription(description)
+	err.SetValue(node.Value)
+	c.findings = append(c.findings, yamlFinding{err: err, position: nodePosition(node, c.documentLine)})
+}
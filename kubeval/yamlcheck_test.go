@@ -0,0 +1,96 @@
+package kubeval
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestCheckYAML(t *testing.T) {
+	input := []byte(`apiVersion: v1
+kind: ReplicationController
+metadata:
+  name: unsafe
+  labels:
+    enabled: yes
+    quoted: "yes"
+spec:
+  replicas: 1
+  replicas: 2
+  selector: &selector
+    app: nginx
+  template:
+    metadata:
+      labels: *selector
+      annotations:
+        <<: *selector
+`)
+	schemaLocation, _ := filepath.Abs("../fixtures/schemas")
+	config := NewDefaultConfig()
+	config.SchemaLocation = "file://" + schemaLocation
+
+	results, err := Validate(input, config)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	if len(results[0].Errors) != 0 {
+		t.Errorf("Expected no errors without CheckYAML, got %v", results[0].Errors)
+	}
+
+	config.CheckYAML = true
+	results, err = Validate(input, config)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	var expected = []struct {
+		Type     string
+		Field    string
+		Position Position
+	}{
+		{Type: "yaml_boolean", Field: "metadata.labels.enabled", Position: Position{Line: 6, Column: 14}},
+		{Type: "duplicate_key", Field: "spec.replicas", Position: Position{Line: 10, Column: 3}},
+		{Type: "alias", Field: "spec.template.metadata.labels", Position: Position{Line: 15, Column: 15}},
+		{Type: "merge_key", Field: "spec.template.metadata.annotations", Position: Position{Line: 17, Column: 9}},
+		{Type: "alias", Field: "spec.template.metadata.annotations", Position: Position{Line: 17, Column: 13}},
+	}
+	result := results[0]
+	if len(result.Errors) != len(expected) || len(result.ErrorPositions) != len(expected) {
+		t.Fatalf("Expected %d errors with positions, got %v at %v", len(expected), result.Errors, result.ErrorPositions)
+	}
+	for i, e := range expected {
+		err := result.Errors[i]
+		if err.Type() != e.Type || err.Field() != e.Field || result.ErrorPositions[i] != e.Position {
+			t.Errorf("Expected %s at %s (%s), got %s at %s (%s): %s", e.Type, e.Field, e.Position, err.Type(), err.Field(), result.ErrorPositions[i], err.String())
+		}
+	}
+}
+
+func TestCheckYAMLList(t *testing.T) {
+	input := []byte(`apiVersion: v1
+kind: List
+items:
+- apiVersion: v1
+  kind: ConfigMap
+  metadata:
+    name: config
+  data:
+    enabled: on
+`)
+	config := NewDefaultConfig()
+	config.IgnoreMissingSchemas = true
+	config.CheckYAML = true
+
+	results, err := Validate(input, config)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	if len(results) != 2 {
+		t.Fatalf("Expected results for the List and its item, got %d", len(results))
+	}
+	// The item is only checked once, rather than again as part of the List
+	if len(results[0].Errors) != 0 {
+		t.Errorf("Expected no errors for the List, got %v", results[0].Errors)
+	}
+	if len(results[1].Errors) != 1 || results[1].Errors[0].Field() != "data.enabled" || results[1].ErrorPositions[0] != (Position{Line: 9, Column: 14}) {
+		t.Errorf("Expected the boolean in the ConfigMap to be reported at line 9, got %v at %v", results[1].Errors, results[1].ErrorPositions)
+	}
+}
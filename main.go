@@ -1,11 +1,10 @@
 package main
 
 import (
-	"bytes"
+	"context"
 	"crypto/tls"
 	"errors"
 	"fmt"
-	"io"
 	"io/ioutil"
 	"net/http"
 	"os"
@@ -21,13 +20,14 @@ import (
 
 	"github.com/instrumenta/kubeval/kubeval"
 	"github.com/instrumenta/kubeval/log"
+	buildversion "github.com/instrumenta/kubeval/version"
 )
 
 var (
-	version                 = "dev"
-	commit                  = "none"
-	date                    = "unknown"
-	directories             = []string{}
+	version             = "dev"
+	commit              = "none"
+	date                = "unknown"
+	directories         = []string{}
 	ignoredPathPatterns = []string{}
 
 	// forceColor tells kubeval to use colored output even if
@@ -42,6 +42,8 @@ var RootCmd = &cobra.Command{
 	Short:   "Validate a Kubernetes YAML file against the relevant schema",
 	Long:    `Validate a Kubernetes YAML file against the relevant schema`,
 	Version: fmt.Sprintf("Version: %s\nCommit: %s\nDate: %s\n", version, commit, date),
+	// Files to validate are passed as arguments alongside the subcommands
+	Args: cobra.ArbitraryArgs,
 	Run: func(cmd *cobra.Command, args []string) {
 		if config.IgnoreMissingSchemas && !config.Quiet {
 			log.Warn("Set to ignore missing schemas")
@@ -57,9 +59,21 @@ var RootCmd = &cobra.Command{
 			}
 		}
 
+		// Share one schema provider between every file, so that CRD and
+		// OpenAPI locations are only read once
+		config.SchemaProvider = kubeval.NewDefaultSchemaProvider(config)
+
+		// Find resources defined more than once across every file, as well
+		// as within each of them
+		config.DuplicateTracker = kubeval.NewDuplicateTracker()
+
 		success := true
 		windowsStdinIssue := false
-		outputManager := kubeval.GetOutputManager(config.OutputFormat)
+		outputManager, err := kubeval.NewOutputManager(config)
+		if err != nil {
+			log.Error(err)
+			os.Exit(1)
+		}
 
 		stat, err := os.Stdin.Stat()
 		if err != nil {
@@ -82,72 +96,40 @@ var RootCmd = &cobra.Command{
 		notty := (stat.Mode() & os.ModeCharDevice) == 0
 		noFileOrDirArgs := (len(args) < 1 || args[0] == "-") && len(directories) < 1
 		if noFileOrDirArgs && !windowsStdinIssue && notty {
-			buffer := new(bytes.Buffer)
-			_, err := io.Copy(buffer, os.Stdin)
-			if err != nil {
-				log.Error(err)
-				os.Exit(1)
-			}
-			schemaCache := kubeval.NewSchemaCache()
+			// Validate stdin as it's read, so that results for large inputs
+			// are reported before the end of the input is reached
+			schemaCache := kubeval.NewSharedSchemaCache()
 			config.FileName = viper.GetString("filename")
-			results, err := kubeval.ValidateWithCache(buffer.Bytes(), schemaCache, config)
+			err := kubeval.ValidateStream(context.Background(), os.Stdin, schemaCache, func(r kubeval.ValidationResult) error {
+				if len(r.Errors) > 0 {
+					success = false
+				}
+				return outputManager.Put(r)
+			}, config)
 			if err != nil {
-				log.Error(err)
-				os.Exit(1)
-			}
-			success = !hasErrors(results)
-
-			for _, r := range results {
-				err = outputManager.Put(r)
-				if err != nil {
+				if err := outputManager.PutError(config.FileName, err); err != nil {
 					log.Error(err)
-					os.Exit(1)
 				}
+				success = false
 			}
 		} else {
 			if len(args) < 1 && len(directories) < 1 {
 				log.Error(errors.New("You must pass at least one file as an argument, or at least one directory to the directories flag"))
 				os.Exit(1)
 			}
-			schemaCache := kubeval.NewSchemaCache()
+			schemaCache := kubeval.NewSharedSchemaCache()
 			files, err := aggregateFiles(args)
 			if err != nil {
 				log.Error(err)
 				success = false
 			}
 
-			var aggResults []kubeval.ValidationResult
-			for _, fileName := range files {
-				filePath, _ := filepath.Abs(fileName)
-				fileContents, err := ioutil.ReadFile(filePath)
-				if err != nil {
-					log.Error(fmt.Errorf("Could not open file %v", fileName))
-					earlyExit()
-					success = false
-					continue
-				}
-				config.FileName = fileName
-				results, err := kubeval.ValidateWithCache(fileContents, schemaCache, config)
-				if err != nil {
-					log.Error(err)
-					earlyExit()
-					success = false
-					continue
-				}
-
-				for _, r := range results {
-					err := outputManager.Put(r)
-					if err != nil {
-						log.Error(err)
-						os.Exit(1)
-					}
-				}
-
-				aggResults = append(aggResults, results...)
+			filesValid, err := validateFiles(files, schemaCache, outputManager)
+			if err != nil {
+				log.Error(err)
+				os.Exit(1)
 			}
-
-			// only use result of hasErrors check if `success` is currently truthy
-			success = success && !hasErrors(aggResults)
+			success = success && filesValid
 		}
 
 		// flush any final logs which may be sitting in the buffer
@@ -163,6 +145,101 @@ var RootCmd = &cobra.Command{
 	},
 }
 
+// resultWriter is the part of an output manager which the results of
+// validating files are written to
+type resultWriter interface {
+	Put(r kubeval.ValidationResult) error
+	PutError(fileName string, err error) error
+}
+
+// parsedFile is a file which has been read and parsed, ready to be
+// validated, or the error reading it
+type parsedFile struct {
+	name  string
+	input *kubeval.Input
+	err   error
+}
+
+// parseFile reads and parses the file with the given name
+func parseFile(fileName string) parsedFile {
+	filePath, _ := filepath.Abs(fileName)
+	contents, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return parsedFile{name: fileName, err: fmt.Errorf("Could not open file %v", fileName)}
+	}
+	return parsedFile{name: fileName, input: kubeval.ParseInput(contents)}
+}
+
+// validateFiles validates the given files in order, writing their results
+// to out, and returns whether every file is valid. It stops at the first
+// file with an error if config.ExitOnError is set. Each file is read and
+// parsed while the ones before it are validated. With a config.Concurrency
+// above one, up to that many files are read ahead, and the schemas for
+// each are downloaded that many at a time before it's validated. The
+// files themselves are still validated one at a time, so that duplicates
+// are reported against the first file to define a resource, and custom
+// resources only use the CustomResourceDefinitions in the files before
+// them, whatever the concurrency.
+func validateFiles(files []string, schemaCache *kubeval.SchemaCache, out resultWriter) (bool, error) {
+	readAhead := 0
+	if config.Concurrency > 1 {
+		readAhead = config.Concurrency
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	parsed := make(chan parsedFile, readAhead)
+	go func() {
+		defer close(parsed)
+		for _, fileName := range files {
+			file := parseFile(fileName)
+			if file.input != nil && config.Concurrency > 1 {
+				kubeval.PrefetchSchemas(ctx, []*kubeval.Input{file.input}, schemaCache, config)
+			}
+			select {
+			case parsed <- file:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	// Stop reading ahead once the files are validated, and wait for any
+	// downloads already started, which may still be using config
+	defer func() {
+		cancel()
+		for range parsed {
+		}
+	}()
+
+	success := true
+	for file := range parsed {
+		var results []kubeval.ValidationResult
+		err := file.err
+		if err == nil {
+			fileConfig := *config
+			fileConfig.FileName = file.name
+			results, err = kubeval.ValidateInput(file.input, schemaCache, &fileConfig)
+		}
+
+		// The results for the resources validated before any error are
+		// written first, as they are when validating stdin
+		for _, r := range results {
+			if err := out.Put(r); err != nil {
+				return false, err
+			}
+		}
+		success = success && !hasErrors(results)
+		if err != nil {
+			if err := out.PutError(file.name, err); err != nil {
+				log.Error(err)
+			}
+			if config.ExitOnError {
+				return false, nil
+			}
+			success = false
+		}
+	}
+	return success, nil
+}
+
 // hasErrors returns truthy if any of the provided results
 // contain errors.
 func hasErrors(res []kubeval.ValidationResult) bool {
@@ -215,15 +292,12 @@ func aggregateFiles(args []string) ([]string, error) {
 	return files, allErrors.ErrorOrNil()
 }
 
-func earlyExit() {
-	if config.ExitOnError {
-		os.Exit(1)
-	}
-}
-
 // Execute adds all child commands to the root command sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
 func Execute() {
+	// Output formats which record the version of kubeval use the one set
+	// at build time
+	buildversion.BuildVersion = version
 	if err := RootCmd.Execute(); err != nil {
 		log.Error(err)
 		os.Exit(-1)
@@ -242,7 +316,7 @@ func init() {
 	RootCmd.Flags().StringSliceVarP(&directories, "directories", "d", []string{}, "A comma-separated list of directories to recursively search for YAML documents")
 	RootCmd.Flags().StringSliceVarP(&ignoredPathPatterns, "ignored-path-patterns", "i", []string{}, "A comma-separated list of regular expressions specifying paths to ignore")
 	RootCmd.Flags().StringSliceVarP(&ignoredPathPatterns, "ignored-filename-patterns", "", []string{}, "An alias for ignored-path-patterns")
-	
+
 	viper.SetEnvPrefix("KUBEVAL")
 	viper.AutomaticEnv()
 	viper.BindPFlag("schema_location", RootCmd.Flags().Lookup("schema-location"))
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/instrumenta/kubeval/kubeval"
+)
+
+// recordingWriter keeps the results and errors written to it
+type recordingWriter struct {
+	results []kubeval.ValidationResult
+	errors  map[string]error
+}
+
+func (w *recordingWriter) Put(r kubeval.ValidationResult) error {
+	w.results = append(w.results, r)
+	return nil
+}
+
+func (w *recordingWriter) PutError(fileName string, err error) error {
+	w.errors[fileName] = err
+	return nil
+}
+
+func TestValidateFilesConcurrently(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "kubeval-files")
+	defer os.RemoveAll(dir)
+	crd, _ := ioutil.ReadFile("fixtures/crd_with_resources.yaml")
+	inputs := []struct {
+		Name     string
+		Contents string
+	}{
+		{"crd.yaml", strings.SplitN(string(crd), "---\n", 2)[0]},
+		{"web.yaml", "apiVersion: v1\nkind: ReplicationController\nmetadata:\n  name: web\n"},
+		{"secret.yaml", strings.SplitN(string(crd), "---\n", 2)[1]},
+		{"web-copy.yaml", "apiVersion: v1\nkind: ReplicationController\nmetadata:\n  name: web\n"},
+	}
+	var files []string
+	for _, input := range inputs {
+		file := filepath.Join(dir, input.Name)
+		ioutil.WriteFile(file, []byte(input.Contents), 0644)
+		files = append(files, file)
+	}
+
+	defer func(saved kubeval.Config) { *config = saved }(*config)
+	schemaLocation, _ := filepath.Abs("fixtures/schemas")
+	config.SchemaLocation = "file://" + schemaLocation
+	config.IgnoreMissingSchemas = true
+	config.Concurrency = len(files)
+
+	// Whatever order the schemas are found in, duplicates are reported
+	// against the first file, and the CRD is used by the files after it
+	for i := 0; i < 20; i++ {
+		config.SchemaProvider = kubeval.NewDefaultSchemaProvider(config)
+		config.DuplicateTracker = kubeval.NewDuplicateTracker()
+		out := &recordingWriter{errors: make(map[string]error)}
+		success, err := validateFiles(files, kubeval.NewSharedSchemaCache(), out)
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err.Error())
+		}
+		if success {
+			t.Errorf("Expected the duplicate to fail validation")
+		}
+		if len(out.errors) != 1 || out.errors[files[3]] == nil || !strings.Contains(out.errors[files[3]].Error(), "first defined at "+files[1]+":1") {
+			t.Fatalf("Expected only the duplicate in %s, first defined in %s, got %v", files[3], files[1], out.errors)
+		}
+		if len(out.results) != 4 || out.results[2].Kind != "SealedSecret" || !out.results[2].ValidatedAgainstSchema {
+			t.Fatalf("Expected the SealedSecret to be validated against the schema from the CRD in %s, got %+v", files[0], out.results)
+		}
+		// The duplicate still has its result written alongside the error
+		if out.results[3].FileName != files[3] || out.results[3].Kind != "ReplicationController" {
+			t.Errorf("Expected the result for the duplicate in %s, got %+v", files[3], out.results[3])
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/instrumenta/kubeval/kubeval"
+	"github.com/instrumenta/kubeval/log"
+)
+
+var pullOptions = kubeval.PullOptions{}
+
+// SchemasCmd groups the commands which manage offline schema bundles
+var SchemasCmd = &cobra.Command{
+	Use:   "schemas",
+	Short: "Manage bundles of schemas for offline use",
+	Long: `Manage bundles of schemas for offline use. A bundle is a directory, or a
+.tar.gz archive of one, which can be used with --schema-location file://<bundle>`,
+}
+
+// SchemasPullCmd downloads schemas into a bundle
+var SchemasPullCmd = &cobra.Command{
+	Use:   "pull",
+	Short: "Download the schemas for the given versions, kinds and groups into a bundle",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		manifest, err := kubeval.PullSchemas(pullOptions)
+		if err != nil {
+			log.Error(err)
+			os.Exit(1)
+		}
+		for _, s := range manifest.Missing {
+			log.Warn("No schema published for", s.Kind, s.APIVersion, "in Kubernetes", s.KubernetesVersion)
+		}
+		log.Success("Pulled", fmt.Sprint(len(manifest.Schemas)), "schemas into", pullOptions.Output)
+	},
+}
+
+// SchemasVerifyCmd checks the integrity of a bundle
+var SchemasVerifyCmd = &cobra.Command{
+	Use:   "verify <bundle>",
+	Short: "Check that every schema in a bundle is present, unmodified and valid",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		manifest, err := kubeval.VerifyBundle(args[0])
+		if err != nil {
+			log.Error(err)
+			os.Exit(1)
+		}
+		log.Success(args[0], "contains", fmt.Sprint(len(manifest.Schemas)), "valid schemas")
+	},
+}
+
+// SchemasListCmd lists the contents of a bundle
+var SchemasListCmd = &cobra.Command{
+	Use:   "list <bundle>",
+	Short: "List the schemas in a bundle",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		manifest, err := kubeval.ListBundle(args[0])
+		if err != nil {
+			log.Error(err)
+			os.Exit(1)
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tAPIVERSION\tKIND\tSTRICT\tPATH")
+		for _, s := range manifest.Schemas {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%t\t%s\n", s.KubernetesVersion, s.APIVersion, s.Kind, s.Strict, s.Path)
+		}
+		for _, s := range manifest.Missing {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%t\t%s\n", s.KubernetesVersion, s.APIVersion, s.Kind, s.Strict, "(missing)")
+		}
+		w.Flush()
+	},
+}
+
+func init() {
+	SchemasPullCmd.Flags().StringSliceVar(&pullOptions.KubernetesVersions, "kubernetes-versions", []string{"master"}, "Comma-separated list of Kubernetes versions to download schemas for")
+	SchemasPullCmd.Flags().StringSliceVar(&pullOptions.Kinds, "kinds", []string{}, "Comma-separated list of case-insensitive kinds to download schemas for")
+	SchemasPullCmd.Flags().StringSliceVar(&pullOptions.Groups, "groups", []string{}, "Comma-separated list of API groups to download schemas for, using 'core' for the core group")
+	SchemasPullCmd.Flags().BoolVar(&pullOptions.Strict, "strict", false, "Also download the strict variant of every schema")
+	SchemasPullCmd.Flags().BoolVar(&pullOptions.OpenShift, "openshift", false, "Download OpenShift schemas instead of upstream Kubernetes")
+	SchemasPullCmd.Flags().StringVarP(&pullOptions.SchemaLocation, "schema-location", "s", "", "Base URL to download schemas from. Can also be specified with the environment variable KUBEVAL_SCHEMA_LOCATION.")
+	SchemasPullCmd.Flags().StringVarP(&pullOptions.Output, "output", "o", "", "Directory to write the bundle to, or a .tar.gz file to write it as an archive")
+	SchemasPullCmd.MarkFlagRequired("output")
+
+	SchemasCmd.AddCommand(SchemasPullCmd)
+	SchemasCmd.AddCommand(SchemasVerifyCmd)
+	SchemasCmd.AddCommand(SchemasListCmd)
+	RootCmd.AddCommand(SchemasCmd)
+}